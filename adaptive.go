@@ -0,0 +1,111 @@
+package pulse
+
+import (
+	"time"
+
+	"github.com/jfreymuth/pulse/proto"
+)
+
+// adaptInterval is how often the adaptive latency controller re-evaluates the buffer size.
+// The buffer shrinks after a full interval without underflows.
+const adaptInterval = 5 * time.Second
+
+type adaptiveLatency struct {
+	min, max  uint32 // in bytes
+	underflow func()
+	stop      chan struct{}
+}
+
+// EnableAdaptiveLatency makes the stream adjust its buffer size automatically.
+// The buffer grows whenever an underflow happens and shrinks slowly while playback is stable,
+// staying between the latencies min and max.
+//
+// The current target can be read with BufferSize or BufferSizeBytes.
+func (p *PlaybackStream) EnableAdaptiveLatency(min, max time.Duration) {
+	if min <= 0 || max < min {
+		panic("pulse: invalid latency bounds")
+	}
+	r := p.reply()
+	frame := uint32(r.Channels) * uint32(p.bytesPerSample)
+	toBytes := func(d time.Duration) uint32 {
+		b := uint32(d.Seconds()*float64(r.Rate)) * frame
+		if b < frame {
+			b = frame
+		}
+		return b
+	}
+	signal := make(chan struct{}, 1)
+	a := &adaptiveLatency{
+		min: toBytes(min),
+		max: toBytes(max),
+		underflow: func() {
+			select {
+			case signal <- struct{}{}:
+			default:
+			}
+		},
+		stop: make(chan struct{}),
+	}
+	p.mu.Lock()
+	old := p.adaptive
+	p.adaptive = a
+	p.mu.Unlock()
+	if old != nil {
+		close(old.stop)
+	}
+	go p.adaptLatency(a, signal, frame)
+}
+
+// DisableAdaptiveLatency stops adjusting the buffer size. The current buffer size is kept.
+func (p *PlaybackStream) DisableAdaptiveLatency() {
+	p.mu.Lock()
+	a := p.adaptive
+	p.adaptive = nil
+	p.mu.Unlock()
+	if a != nil {
+		close(a.stop)
+	}
+}
+
+func (p *PlaybackStream) adaptLatency(a *adaptiveLatency, underflow <-chan struct{}, frame uint32) {
+	t := time.NewTicker(adaptInterval)
+	defer t.Stop()
+	stable := true
+	for {
+		grow := false
+		select {
+		case <-a.stop:
+			return
+		case <-underflow:
+			stable = false
+			grow = true
+		case <-t.C:
+			if !stable {
+				stable = true
+				continue
+			}
+		}
+		r := p.reply()
+		target := r.BufferTargetLength
+		if grow {
+			target *= 2
+		} else {
+			target -= target / 4
+		}
+		if target < a.min {
+			target = a.min
+		}
+		if target > a.max {
+			target = a.max
+		}
+		target -= target % frame
+		if target == r.BufferTargetLength {
+			continue
+		}
+		maxLength := r.BufferMaxLength
+		if maxLength < 2*target {
+			maxLength = 2 * target
+		}
+		p.setBufferAttr(maxLength, target, proto.Undefined, proto.Undefined)
+	}
+}
@@ -0,0 +1,76 @@
+package pulse
+
+import "github.com/jfreymuth/pulse/proto"
+
+// A Card is a sound card, which provides sinks and sources depending on the active profile.
+type Card struct {
+	c    *Client
+	info proto.GetCardInfoReply
+}
+
+// A Profile is a configuration of a card, e.g. stereo output, or headset mode with a microphone.
+type Profile struct {
+	Name        string
+	Description string
+	Priority    int
+	// Available is false if the profile can't currently be used, e.g. because nothing is plugged in.
+	Available bool
+}
+
+// ListCards returns a list of all sound cards.
+func (c *Client) ListCards() ([]*Card, error) {
+	var reply proto.GetCardInfoListReply
+	err := c.c().Request(&proto.GetCardInfoList{}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	cards := make([]*Card, len(reply))
+	for i := range cards {
+		cards[i] = &Card{c: c, info: *reply[i]}
+	}
+	return cards, nil
+}
+
+// ID returns the card name. Card names are unique identifiers, but not necessarily human-readable.
+func (c *Card) ID() string {
+	return c.info.CardName
+}
+
+// Name is a human-readable name describing the card.
+func (c *Card) Name() string {
+	if d, ok := c.info.Properties["device.description"]; ok {
+		return d.String()
+	}
+	return c.info.CardName
+}
+
+// CardIndex returns the card index.
+// This should only be used together with (*Client).RawRequest.
+func (c *Card) CardIndex() uint32 {
+	return c.info.CardIndex
+}
+
+// Profiles returns the card's profiles.
+func (c *Card) Profiles() []Profile {
+	profiles := make([]Profile, len(c.info.Profiles))
+	for i, p := range c.info.Profiles {
+		profiles[i] = Profile{p.Name, p.Description, int(p.Priority), p.Available != 0}
+	}
+	return profiles
+}
+
+// ActiveProfile returns the name of the profile that is currently used.
+func (c *Card) ActiveProfile() string {
+	return c.info.ActiveProfileName
+}
+
+// SetProfile activates the profile with the given name.
+// Changing the profile may remove sinks and sources and create new ones.
+func (c *Card) SetProfile(name string) error {
+	err := c.c.c().Request(&proto.SetCardProfile{CardIndex: c.info.CardIndex, ProfileName: name}, nil)
+	if err != nil {
+		return err
+	}
+	c.info.ActiveProfileName = name
+	return nil
+}
@@ -1,30 +1,66 @@
 package pulse
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"path"
 	"sync"
+	"time"
 
 	"github.com/jfreymuth/pulse/proto"
 )
 
 // The Client is the connection to the pulseaudio server. An application typically only uses a single client.
 type Client struct {
-	conn net.Conn
-	c    *proto.Client
+	conn net.Conn      // protected by mu
+	pc   *proto.Client // protected by mu, see c
 
-	mu       sync.Mutex
-	playback map[uint32]*PlaybackStream
-	record   map[uint32]*RecordStream
+	mu         sync.Mutex
+	playback   map[uint32]*PlaybackStream
+	record     map[uint32]*RecordStream
+	underflows []time.Time
 
-	server string
-	props  proto.PropList
+	autoRestore  bool
+	reconnect    bool
+	disconnected bool
+	closing      bool
+	closed       chan error
+	closedSent   bool
+	done         chan struct{}
+	err          error
+	lostPlayback []*PlaybackStream
+	lostRecord   []*RecordStream
+
+	subscribers map[chan Event]proto.SubscriptionMask
+	roleMuted   map[uint32]bool // sink inputs muted by CorkStreamsByRole
+
+	server     string
+	cookie     []byte
+	cookiePath string
+	useX11     bool
+	props      proto.PropList
 }
 
 // NewClient connects to the server.
 func NewClient(opts ...ClientOption) (*Client, error) {
+	return dial(context.Background(), opts)
+}
+
+// DialContext connects to the server at the given address, which is a server string like "tcp:host:4713"
+// or the path of a unix socket. The address takes precedence over the ClientServerString option.
+// If the context is done before the connection is established, DialContext gives up and returns the context's error.
+//
+// The authentication cookie is taken from the ClientCookie or ClientCookiePath option, if one is used,
+// otherwise it is read from the file named by the environment variable PULSE_COOKIE, or from ~/.config/pulse/cookie.
+func DialContext(ctx context.Context, address string, opts ...ClientOption) (*Client, error) {
+	opts = append(opts[:len(opts):len(opts)], ClientServerString(address))
+	return dial(ctx, opts)
+}
+
+func dial(ctx context.Context, opts []ClientOption) (*Client, error) {
 	c := &Client{
 		props: proto.PropList{
 			"media.name":                 proto.PropListString("go audio"),
@@ -39,78 +75,370 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 		opt(c)
 	}
 
-	var err error
-	c.c, c.conn, err = proto.Connect(c.server)
+	if c.cookie == nil && c.cookiePath != "" {
+		cookie, err := ioutil.ReadFile(c.cookiePath)
+		if err != nil {
+			return nil, err
+		}
+		c.cookie = cookie
+	}
+
+	if c.useX11 {
+		server, cookie := x11Properties()
+		if c.server == "" {
+			c.server = server
+		}
+		if c.cookie == nil {
+			c.cookie = cookie
+		}
+	}
+	if c.cookie != nil && len(c.cookie) != cookieLength {
+		return nil, errInvalidCookie
+	}
+
+	c.playback = make(map[uint32]*PlaybackStream)
+	c.record = make(map[uint32]*RecordStream)
+	err := c.connect(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return c, nil
+}
 
-	err = c.c.Request(&proto.SetClientName{Props: c.props}, &proto.SetClientNameReply{})
+// connect opens a new connection to the server.
+func (c *Client) connect(ctx context.Context) error {
+	pc, conn, err := proto.ConnectContext(ctx, c.server, c.cookie)
 	if err != nil {
-		c.conn.Close()
-		return nil, err
+		return err
+	}
+
+	err = pc.Request(&proto.SetClientName{Props: c.props}, &proto.SetClientNameReply{})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c.mu.Lock()
+	if c.closing {
+		// Close was called while connecting
+		c.mu.Unlock()
+		conn.Close()
+		return ErrConnectionClosed
+	}
+	c.pc, c.conn = pc, conn
+	c.disconnected = false
+	if c.closed == nil || c.closedSent {
+		c.closed = make(chan error, 1)
+		c.closedSent = false
+		c.done = make(chan struct{})
+		c.err = nil
+	}
+	c.mu.Unlock()
+	pc.Callback = func(msg interface{}) { c.dispatch(pc, msg) }
+	return nil
+}
+
+// c returns the current connection to the server, it is replaced when the client reconnects.
+func (c *Client) c() *proto.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pc
+}
+
+// dispatch handles messages sent by the server on the connection pc.
+// Messages from connections that have been replaced by Reconnect are ignored.
+func (c *Client) dispatch(pc *proto.Client, msg interface{}) {
+	c.mu.Lock()
+	current := c.pc == pc
+	c.mu.Unlock()
+	if !current {
+		return
+	}
+	switch msg := msg.(type) {
+	case *proto.Request:
+		c.mu.Lock()
+		stream, ok := c.playback[msg.StreamIndex]
+		c.mu.Unlock()
+		if ok {
+			stream.sendRequest(int(msg.Length))
+		}
+	case *proto.DataPacket:
+		c.mu.Lock()
+		stream, ok := c.record[msg.StreamIndex]
+		c.mu.Unlock()
+		if ok {
+			stream.write(msg.Data)
+		}
+	case *proto.Started:
+		c.mu.Lock()
+		stream, ok := c.playback[msg.StreamIndex]
+		c.mu.Unlock()
+		if ok {
+			stream.serverStarted()
+		}
+	case *proto.Underflow:
+		c.mu.Lock()
+		stream, ok := c.playback[msg.StreamIndex]
+		c.underflows = append(c.recentUnderflows(), time.Now())
+		c.mu.Unlock()
+		if ok {
+			stream.serverUnderflow()
+		}
+	case *proto.PlaybackStreamMoved:
+		c.mu.Lock()
+		stream, ok := c.playback[msg.StreamIndex]
+		c.mu.Unlock()
+		if ok {
+			stream.moved(msg)
+		}
+	case *proto.PlaybackStreamSuspended:
+		c.mu.Lock()
+		stream, ok := c.playback[msg.StreamIndex]
+		c.mu.Unlock()
+		if ok {
+			stream.suspended(msg.Suspended)
+		}
+	case *proto.PlaybackStreamEvent:
+		c.mu.Lock()
+		stream, ok := c.playback[msg.StreamIndex]
+		c.mu.Unlock()
+		if ok {
+			switch msg.Event {
+			case "request-cork":
+				stream.serverCork(true)
+			case "request-uncork":
+				stream.serverCork(false)
+			}
+		}
+	case *proto.SubscribeEvent:
+		c.event(msg)
+	case *proto.ConnectionClosed:
+		if c.connectionLost() {
+			c.lost(msg.Err)
+		}
+	default:
+		//fmt.Printf("%#v\n", msg)
 	}
+}
 
+// connectionLost marks all streams as lost and closes the connection.
+// It returns false if the connection was already lost.
+func (c *Client) connectionLost() bool {
+	c.mu.Lock()
+	if c.disconnected {
+		c.mu.Unlock()
+		return false
+	}
+	c.disconnected = true
+	keep := c.autoRestore || c.reconnect
+	playback := c.playback
+	if keep {
+		for _, p := range playback {
+			c.lostPlayback = append(c.lostPlayback, p)
+		}
+	}
+	record := c.record
+	if keep {
+		for _, r := range record {
+			c.lostRecord = append(c.lostRecord, r)
+		}
+	}
 	c.playback = make(map[uint32]*PlaybackStream)
 	c.record = make(map[uint32]*RecordStream)
-	c.c.Callback = func(msg interface{}) {
-		switch msg := msg.(type) {
-		case *proto.Request:
-			c.mu.Lock()
-			stream, ok := c.playback[msg.StreamIndex]
-			c.mu.Unlock()
-			if ok {
-				stream.request <- int(msg.Length)
-			}
-		case *proto.DataPacket:
-			c.mu.Lock()
-			stream, ok := c.record[msg.StreamIndex]
-			c.mu.Unlock()
-			if ok {
-				stream.write(msg.Data)
-			}
-		case *proto.Started:
-			c.mu.Lock()
-			stream, ok := c.playback[msg.StreamIndex]
-			c.mu.Unlock()
-			if ok && stream.state == running && !stream.underflow {
-				stream.started <- true
-			}
-		case *proto.Underflow:
-			c.mu.Lock()
-			stream, ok := c.playback[msg.StreamIndex]
-			c.mu.Unlock()
-			if ok {
-				if stream.state == running {
-					stream.underflow = true
-				}
-			}
-		case *proto.ConnectionClosed:
-			c.mu.Lock()
-			for _, p := range c.playback {
-				close(p.request)
-				p.err = ErrConnectionClosed
-				p.state = serverLost
-			}
-			for _, r := range c.record {
-				r.err = ErrConnectionClosed
-				r.state = serverLost
-			}
-			c.playback = make(map[uint32]*PlaybackStream)
-			c.record = make(map[uint32]*RecordStream)
-			c.mu.Unlock()
-			c.conn.Close()
-		default:
-			//fmt.Printf("%#v\n", msg)
+	conn := c.conn
+	c.mu.Unlock()
+	for _, p := range playback {
+		p.lost()
+		if !keep {
+			p.end()
+		}
+	}
+	for _, r := range record {
+		r.lost()
+		if !keep {
+			r.end()
 		}
 	}
+	conn.Close()
+	return true
+}
 
-	return c, nil
+// lost is called when the server closed the connection, it reconnects if SetReconnect was used.
+func (c *Client) lost(err error) {
+	c.mu.Lock()
+	closing, reconnect := c.closing, c.reconnect
+	c.mu.Unlock()
+	if closing {
+		c.sendClosed(nil)
+	} else if reconnect {
+		go c.autoReconnect(err)
+	} else {
+		c.sendClosed(err)
+	}
+}
+
+// Reconnection attempts start after reconnectDelay and are repeated with increasing delays
+// until reconnectTimeout has passed.
+const (
+	reconnectDelay    = 100 * time.Millisecond
+	reconnectMaxDelay = 2 * time.Second
+	reconnectTimeout  = 30 * time.Second
+)
+
+func (c *Client) autoReconnect(err error) {
+	deadline := time.Now().Add(reconnectTimeout)
+	delay := reconnectDelay
+	for time.Now().Before(deadline) {
+		time.Sleep(delay)
+		c.mu.Lock()
+		stop := c.closing || !c.reconnect
+		c.mu.Unlock()
+		if stop {
+			break
+		}
+		if c.connect(context.Background()) == nil {
+			c.resume()
+			return
+		}
+		if delay *= 2; delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+	c.mu.Lock()
+	var playback []*PlaybackStream
+	var record []*RecordStream
+	if !c.autoRestore {
+		playback, record = c.lostPlayback, c.lostRecord
+		c.lostPlayback, c.lostRecord = nil, nil
+	}
+	c.mu.Unlock()
+	for _, p := range playback {
+		p.end()
+	}
+	for _, r := range record {
+		r.end()
+	}
+	c.sendClosed(err)
+}
+
+// sendClosed notifies the receiver of the channel returned by Closed and closes the channel returned by Done.
+func (c *Client) sendClosed(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closedSent {
+		if err != nil {
+			c.closed <- err
+			c.err = err
+		} else {
+			c.err = ErrConnectionClosed
+		}
+		close(c.closed)
+		close(c.done)
+		c.closedSent = true
+	}
+}
+
+// SetReconnect enables or disables automatic reconnection.
+// If enabled, the client connects again when the connection to the server is lost, e.g. because the server was restarted,
+// and restores all streams as if the ClientAutoRestoreStreams option was used.
+// Reconnection is attempted for 30 seconds, after that the client gives up and the channel returned by Closed is notified.
+func (c *Client) SetReconnect(enabled bool) {
+	c.mu.Lock()
+	c.reconnect = enabled
+	c.mu.Unlock()
+}
+
+// Closed returns a channel that receives the error that caused the connection to be lost, and is closed afterwards.
+// If automatic reconnection is enabled, this only happens if reconnecting fails.
+// If the client is closed with Close, the channel is closed without receiving an error.
+// After a successful call to Reconnect, Closed returns a new channel.
+func (c *Client) Closed() <-chan error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// Done returns a channel that is closed when the connection to the server is lost for good,
+// i.e. under the same conditions as the channel returned by Closed.
+// After a successful call to Reconnect, Done returns a new channel.
+func (c *Client) Done() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done
+}
+
+// Err returns nil until the channel returned by Done is closed.
+// After that, it returns the error that caused the connection to be lost,
+// or ErrConnectionClosed if the client was closed with Close.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Reconnect closes the connection to the server, if it is still open, and connects again.
+// This can be used to recover after the server was restarted.
+// Streams created before are closed, unless the ClientAutoRestoreStreams option was used or automatic reconnection is enabled.
+// Subscriptions remain active, but events that happened while the client was disconnected are lost.
+//
+// Reconnect must not be called concurrently with other methods of the client or its streams.
+func (c *Client) Reconnect() error {
+	c.connectionLost()
+	err := c.connect(context.Background())
+	if err != nil {
+		c.sendClosed(err)
+		return err
+	}
+	return c.resume()
+}
+
+// resume restores streams and subscriptions after connecting again.
+func (c *Client) resume() error {
+	c.mu.Lock()
+	restore := c.autoRestore || c.reconnect
+	subscribed := len(c.subscribers) > 0
+	c.mu.Unlock()
+	if restore {
+		c.restoreStreams()
+	}
+	if subscribed {
+		return c.updateSubscription()
+	}
+	return nil
+}
+
+// restoreStreams recreates the streams that were lost with the previous connection.
+func (c *Client) restoreStreams() {
+	c.mu.Lock()
+	playback, record := c.lostPlayback, c.lostRecord
+	c.lostPlayback, c.lostRecord = nil, nil
+	c.mu.Unlock()
+	for _, p := range playback {
+		if err := p.create(); err != nil {
+			p.end()
+		} else if p.restore {
+			p.Start()
+		}
+	}
+	for _, r := range record {
+		r.mu.Lock()
+		restore := r.restore
+		r.mu.Unlock()
+		if err := r.create(); err != nil {
+			r.end()
+		} else if restore {
+			r.Start()
+		}
+	}
 }
 
 // Close closes the client. Calling methods on a closed client may panic.
 func (c *Client) Close() {
-	c.conn.Close()
+	c.mu.Lock()
+	c.closing = true
+	conn := c.conn
+	c.mu.Unlock()
+	conn.Close()
 }
 
 // A ClientOption supplies configuration when creating the client.
@@ -129,6 +457,16 @@ func ClientApplicationIconName(name string) ClientOption {
 	return func(c *Client) { c.props["application.icon_name"] = proto.PropListString(name) }
 }
 
+// ClientProperties sets properties of the client, e.g. "application.name" or "application.process.id".
+// They override the default properties and are sent to the server whenever the client connects.
+func ClientProperties(props map[string]string) ClientOption {
+	return func(c *Client) {
+		for k, v := range props {
+			c.props[k] = proto.PropListString(v)
+		}
+	}
+}
+
 // ClientServerString will override the default server strings.
 // Server strings are used to connect to the server. For the server string format see
 // https://www.freedesktop.org/wiki/Software/PulseAudio/Documentation/User/ServerStrings/
@@ -136,6 +474,151 @@ func ClientServerString(s string) ClientOption {
 	return func(c *Client) { c.server = s }
 }
 
+// ClientCookie sets the authentication cookie, which must be 256 bytes long.
+// It takes precedence over the ClientCookiePath option and the cookie files.
+func ClientCookie(cookie []byte) ClientOption {
+	return func(c *Client) { c.cookie = cookie }
+}
+
+// ClientCookiePath sets the file the authentication cookie is read from,
+// instead of the file named by the environment variable PULSE_COOKIE or ~/.config/pulse/cookie.
+func ClientCookiePath(path string) ClientOption {
+	return func(c *Client) { c.cookiePath = path }
+}
+
+// ClientAutoRestoreStreams makes Reconnect recreate all streams that were lost with the previous connection,
+// using their original options, reader or writer. Streams that were running are started again.
+// Streams that can't be recreated, e.g. because their sink no longer exists, stay closed.
+func ClientAutoRestoreStreams() ClientOption {
+	return func(c *Client) { c.autoRestore = true }
+}
+
+// ClientUseX11 will read the server string and authentication cookie from the
+// PULSE_SERVER and PULSE_COOKIE properties of the X11 root window, if they are set.
+// An explicit server string set by ClientServerString takes precedence.
+//
+// X11 support is only compiled in when building with the x11 build tag,
+// otherwise this option has no effect.
+func ClientUseX11() ClientOption {
+	return func(c *Client) { c.useX11 = true }
+}
+
+// ServerInfo contains information about the server.
+type ServerInfo struct {
+	ServerName    string // e.g. "pulseaudio"
+	ServerVersion string
+	Username      string // the user the server is running as
+	Hostname      string
+
+	DefaultSink   string // the name of the default sink, see (*Sink).ID
+	DefaultSource string // the name of the default source, see (*Source).ID
+
+	SampleSpec proto.SampleSpec // the default sample spec
+	ChannelMap proto.ChannelMap // the default channel map
+}
+
+// ServerInfo requests information about the server.
+func (c *Client) ServerInfo() (*ServerInfo, error) {
+	var info proto.GetServerInfoReply
+	err := c.c().Request(&proto.GetServerInfo{}, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &ServerInfo{
+		ServerName:    info.PackageName,
+		ServerVersion: info.PackageVersion,
+		Username:      info.Username,
+		Hostname:      info.Hostname,
+		DefaultSink:   info.DefaultSinkName,
+		DefaultSource: info.DefaultSourceName,
+		SampleSpec:    info.DefaultSampleSpec,
+		ChannelMap:    info.DefaultChannelMap,
+	}, nil
+}
+
+// DefaultSampleSpec returns the server's default sample spec and channel map.
+// Streams that use these settings usually don't need any conversion on the server.
+func (c *Client) DefaultSampleSpec() (proto.SampleSpec, proto.ChannelMap, error) {
+	info, err := c.ServerInfo()
+	if err != nil {
+		return proto.SampleSpec{}, nil, err
+	}
+	return info.SampleSpec, info.ChannelMap, nil
+}
+
+// Flush makes sure all requests sent so far have been written to the connection.
+// Requests are written synchronously, so this is effectively a no-op that returns the last write error, if any.
+// Note that every request waits for the server's reply, so requests made from a single goroutine
+// are always applied in order.
+func (c *Client) Flush() error {
+	return c.c().Flush()
+}
+
+// StreamLatencies returns the current latency of all playback streams of this client, indexed by stream index.
+// The requests are sent concurrently, so this takes about as long as a single request.
+//
+// Streams whose latency can't be determined are left out of the result. Streams that were closed in the meantime
+// are skipped silently, for other failures the first error is returned together with the latencies of the other streams.
+func (c *Client) StreamLatencies() (map[uint32]time.Duration, error) {
+	c.mu.Lock()
+	streams := make([]*PlaybackStream, 0, len(c.playback))
+	for _, p := range c.playback {
+		streams = append(streams, p)
+	}
+	c.mu.Unlock()
+
+	latencies := make([]time.Duration, len(streams))
+	errs := make([]error, len(streams))
+	var wg sync.WaitGroup
+	wg.Add(len(streams))
+	for i, p := range streams {
+		go func(i int, p *PlaybackStream) {
+			latencies[i], errs[i] = p.latency()
+			wg.Done()
+		}(i, p)
+	}
+	wg.Wait()
+
+	result := make(map[uint32]time.Duration, len(streams))
+	var err error
+	for i, p := range streams {
+		if errs[i] != nil {
+			if err == nil && !p.Closed() {
+				err = errs[i]
+			}
+			continue
+		}
+		result[p.index] = latencies[i]
+	}
+	return result, err
+}
+
+// ServerOverloaded reports whether the server seems to be unable to keep up with playback.
+// PulseAudio does not announce this directly, instead it is derived from underflows of this client's playback streams:
+// the server is considered overloaded if more than 3 underflows occurred during the last 10 seconds.
+// Applications can use this as a hint to reduce their audio workload, e.g. by increasing latency or closing streams.
+func (c *Client) ServerOverloaded() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.underflows = c.recentUnderflows()
+	return len(c.underflows) > overloadUnderflows
+}
+
+const (
+	overloadWindow     = 10 * time.Second
+	overloadUnderflows = 3
+)
+
+// recentUnderflows returns the underflows within overloadWindow. c.mu must be held.
+func (c *Client) recentUnderflows() []time.Time {
+	limit := time.Now().Add(-overloadWindow)
+	i := 0
+	for i < len(c.underflows) && c.underflows[i].Before(limit) {
+		i++
+	}
+	return c.underflows[i:]
+}
+
 // RawRequest can be used to send arbitrary requests.
 //
 // req should be one of the request types defined by the proto package.
@@ -146,12 +629,25 @@ func ClientServerString(s string) ClientOption {
 //
 // The function will always block until the server has replied, even if rpl is nil.
 func (c *Client) RawRequest(req proto.RequestArgs, rpl proto.Reply) error {
-	return c.c.Request(req, rpl)
+	return c.c().Request(req, rpl)
+}
+
+// RequestContext is like RawRequest, but waits for the reply until ctx is done instead of using the default timeout.
+// If ctx is done first, RequestContext returns ctx.Err(). A reply that arrives later is discarded and rpl is not modified.
+func (c *Client) RequestContext(ctx context.Context, req proto.RequestArgs, rpl proto.Reply) error {
+	return c.c().RequestContext(ctx, req, rpl)
 }
 
 // ErrConnectionClosed is a special error value indicating that the server closed the connection.
 const ErrConnectionClosed = pulseError("pulseaudio: connection closed")
 
+// ErrStreamClosed is returned when trying to start a stream that was closed.
+const ErrStreamClosed = pulseError("pulse: stream closed")
+
+const cookieLength = 256
+
+const errInvalidCookie = pulseError("pulse: authentication cookie must be 256 bytes long")
+
 type pulseError string
 
 func (e pulseError) Error() string { return string(e) }
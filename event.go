@@ -0,0 +1,86 @@
+package pulse
+
+import (
+	"strconv"
+
+	"github.com/jfreymuth/pulse/proto"
+)
+
+// An Event notifies about a change on the server.
+type Event struct {
+	Type     proto.SubscriptionEventType // one of proto.EventNew, proto.EventChange or proto.EventRemove
+	Facility proto.SubscriptionEventType // the kind of object that changed, e.g. proto.EventSink
+	Index    uint32                      // the index of the object that changed
+}
+
+// String returns a description of the event, e.g. "new sink 3".
+func (e Event) String() string {
+	return (e.Type | e.Facility).String() + " " + strconv.FormatUint(uint64(e.Index), 10)
+}
+
+// eventBuffer is the number of events that are buffered for each subscriber.
+const eventBuffer = 64
+
+// Subscribe requests notifications about changes of the objects selected by mask, e.g. proto.SubscriptionMaskSink.
+// The events are sent to the returned channel, calling the returned function ends the subscription and closes the channel.
+//
+// The channel buffers a limited number of events. If the receiver falls behind, further events are dropped,
+// so a receiver should request the current state of the objects it is interested in, instead of relying on
+// seeing every single event.
+func (c *Client) Subscribe(mask proto.SubscriptionMask) (<-chan Event, func(), error) {
+	ch := make(chan Event, eventBuffer)
+	c.mu.Lock()
+	if c.subscribers == nil {
+		c.subscribers = make(map[chan Event]proto.SubscriptionMask)
+	}
+	c.subscribers[ch] = mask
+	c.mu.Unlock()
+	err := c.updateSubscription()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.subscribers, ch)
+		c.mu.Unlock()
+		return nil, nil, err
+	}
+	unsubscribe := func() {
+		c.mu.Lock()
+		_, ok := c.subscribers[ch]
+		delete(c.subscribers, ch)
+		if ok {
+			close(ch)
+		}
+		c.mu.Unlock()
+		if ok {
+			c.updateSubscription()
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+// updateSubscription tells the server which events are needed by the current subscribers.
+func (c *Client) updateSubscription() error {
+	c.mu.Lock()
+	var mask proto.SubscriptionMask
+	for _, m := range c.subscribers {
+		mask |= m
+	}
+	pc := c.pc
+	c.mu.Unlock()
+	return pc.Request(&proto.Subscribe{Mask: mask}, nil)
+}
+
+// event sends an event to all interested subscribers.
+func (c *Client) event(msg *proto.SubscribeEvent) {
+	e := Event{Type: msg.Event.GetType(), Facility: msg.Event.GetFacility(), Index: msg.Index}
+	bit := proto.SubscriptionMask(1) << e.Facility
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch, mask := range c.subscribers {
+		if mask&bit != 0 {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package pulse
 import (
 	"io"
 	"reflect"
+	"sync"
 	"unsafe"
 
 	"github.com/jfreymuth/pulse/proto"
@@ -39,6 +40,12 @@ type Int32Reader func([]int32) (int, error)
 // the number of float32 values read, not the number of bytes.
 type Float32Reader func([]float32) (int, error)
 
+// Float64Reader implements the Reader interface.
+// The semantics are the same as io.Reader's Read, but it returns
+// the number of float64 values read, not the number of bytes.
+// The samples are converted to 32 bit floats, since the server doesn't support 64 bit samples.
+type Float64Reader func([]float64) (int, error)
+
 // NewReader creates a reader from an io.Reader and a format.
 // The format must be one of the constants defined in the proto package.
 func NewReader(r io.Reader, format byte) Reader {
@@ -65,6 +72,12 @@ type Int32Writer func([]int32) (int, error)
 // the number of float32 values written, not the number of bytes.
 type Float32Writer func([]float32) (int, error)
 
+// Float64Writer implements the Writer interface.
+// The semantics are the same as io.Writer's Write, but it returns
+// the number of float64 values written, not the number of bytes.
+// The samples are recorded as 32 bit floats and converted, since the server doesn't support 64 bit samples.
+type Float64Writer func([]float64) (int, error)
+
 // NewWriter creates a writer from an io.Writer and a format.
 // The format must be one of the constants defined in the proto package.
 func NewWriter(w io.Writer, format byte) Writer {
@@ -93,6 +106,18 @@ func (c Float32Reader) Read(buf []byte) (int, error) {
 }
 func (c Float32Reader) Format() byte { return formatF32 }
 
+func (c Float64Reader) Read(buf []byte) (int, error) {
+	out := float32Slice(buf)
+	tmp := getFloat64Buffer(len(out))
+	n, err := c(*tmp)
+	for i, v := range (*tmp)[:n] {
+		out[i] = float32(v)
+	}
+	float64Pool.Put(tmp)
+	return n * 4, err
+}
+func (c Float64Reader) Format() byte { return formatF32 }
+
 func (c Uint8Writer) Write(buf []byte) (int, error) { return c(buf) }
 func (c Uint8Writer) Format() byte                  { return proto.FormatUint8 }
 
@@ -114,6 +139,31 @@ func (c Float32Writer) Write(buf []byte) (int, error) {
 }
 func (c Float32Writer) Format() byte { return formatF32 }
 
+func (c Float64Writer) Write(buf []byte) (int, error) {
+	in := float32Slice(buf)
+	tmp := getFloat64Buffer(len(in))
+	for i, v := range in {
+		(*tmp)[i] = float64(v)
+	}
+	n, err := c(*tmp)
+	float64Pool.Put(tmp)
+	return n * 4, err
+}
+func (c Float64Writer) Format() byte { return formatF32 }
+
+// float64Pool holds temporary buffers for Float64Reader and Float64Writer.
+var float64Pool sync.Pool
+
+func getFloat64Buffer(n int) *[]float64 {
+	b, _ := float64Pool.Get().(*[]float64)
+	if b == nil || cap(*b) < n {
+		s := make([]float64, n)
+		return &s
+	}
+	*b = (*b)[:n]
+	return b
+}
+
 type reader struct {
 	r io.Reader
 	f byte
@@ -132,23 +182,46 @@ func (w *writer) Format() byte                  { return w.f }
 
 func bytes(f byte) int {
 	switch f {
-	case proto.FormatUint8:
+	case proto.FormatUint8, proto.FormatALaw, proto.FormatMuLaw:
 		return 1
 	case proto.FormatInt16LE, proto.FormatInt16BE:
 		return 2
-	case proto.FormatInt32LE, proto.FormatInt32BE, proto.FormatFloat32LE, proto.FormatFloat32BE:
+	case proto.FormatInt24LE, proto.FormatInt24BE:
+		return 3
+	case proto.FormatInt32LE, proto.FormatInt32BE, proto.FormatFloat32LE, proto.FormatFloat32BE,
+		proto.FormatInt24_32LE, proto.FormatInt24_32BE:
 		return 4
 	}
 	panic("pulse: invalid format")
 }
 
+// silence returns the byte value that represents silence in the given format.
+func silence(f byte) byte {
+	switch f {
+	case proto.FormatUint8:
+		return 0x80
+	case proto.FormatALaw:
+		return 0xD5
+	case proto.FormatMuLaw:
+		return 0xFF
+	}
+	return 0
+}
+
 func check(f byte) {
+	if !validFormat(f) {
+		panic("pulse: invalid format")
+	}
+}
+
+func validFormat(f byte) bool {
 	switch f {
-	case proto.FormatUint8, proto.FormatInt16LE, proto.FormatInt16BE,
-		proto.FormatInt32LE, proto.FormatInt32BE, proto.FormatFloat32LE, proto.FormatFloat32BE:
-		return
+	case proto.FormatUint8, proto.FormatALaw, proto.FormatMuLaw, proto.FormatInt16LE, proto.FormatInt16BE,
+		proto.FormatInt32LE, proto.FormatInt32BE, proto.FormatFloat32LE, proto.FormatFloat32BE,
+		proto.FormatInt24LE, proto.FormatInt24BE, proto.FormatInt24_32LE, proto.FormatInt24_32BE:
+		return true
 	}
-	panic("pulse: invalid format")
+	return false
 }
 
 var formatI16, formatI32, formatF32 byte
@@ -0,0 +1,38 @@
+package pulse
+
+// Keepalive streams use a low sample rate and a large buffer, so they need very few wakeups.
+const (
+	keepaliveRate    = 8000
+	keepaliveLatency = 1
+)
+
+// NewKeepalive creates and starts a stream that plays silence to the sink, preventing it from being suspended.
+// This avoids audible pops from devices that power down when idle, e.g. between tracks.
+// If sink is nil, the default sink is used. Close the stream to release the sink.
+func (c *Client) NewKeepalive(sink *Sink) (*PlaybackStream, error) {
+	opts := []PlaybackOption{
+		PlaybackMono,
+		PlaybackSampleRate(keepaliveRate),
+		PlaybackLatency(keepaliveLatency),
+		PlaybackMediaName("keepalive"),
+	}
+	if sink != nil {
+		opts = append(opts, PlaybackSink(sink))
+	}
+	p, err := c.NewPlayback(Int16Reader(silentReader), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Start(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func silentReader(buf []int16) (int, error) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	return len(buf), nil
+}
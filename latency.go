@@ -0,0 +1,19 @@
+package pulse
+
+import "github.com/jfreymuth/pulse/proto"
+
+// LatencyToBytes returns the size in bytes of a buffer holding the given amount of audio.
+// The result is always a multiple of the frame size. LatencyToBytes panics if the spec has an invalid format.
+func LatencyToBytes(seconds float64, spec proto.SampleSpec) int {
+	return int(seconds*float64(spec.Rate)) * int(spec.Channels) * bytes(spec.Format)
+}
+
+// BytesToLatency returns the duration in seconds of n bytes of audio. It is the inverse of LatencyToBytes.
+// BytesToLatency panics if the spec has an invalid format.
+func BytesToLatency(n int, spec proto.SampleSpec) float64 {
+	frame := int(spec.Channels) * bytes(spec.Format)
+	if frame == 0 || spec.Rate == 0 {
+		return 0
+	}
+	return float64(n/frame) / float64(spec.Rate)
+}
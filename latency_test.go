@@ -0,0 +1,37 @@
+package pulse
+
+import (
+	"testing"
+
+	"github.com/jfreymuth/pulse/proto"
+)
+
+func TestLatencyToBytes(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		spec    proto.SampleSpec
+		bytes   int
+	}{
+		{1, proto.SampleSpec{Format: proto.FormatUint8, Channels: 1, Rate: 8000}, 8000},
+		{0.5, proto.SampleSpec{Format: proto.FormatInt16LE, Channels: 2, Rate: 44100}, 88200},
+		{0.1, proto.SampleSpec{Format: proto.FormatFloat32LE, Channels: 2, Rate: 48000}, 38400},
+		{0.02, proto.SampleSpec{Format: proto.FormatInt32BE, Channels: 6, Rate: 96000}, 46080},
+		{0, proto.SampleSpec{Format: proto.FormatInt16BE, Channels: 1, Rate: 44100}, 0},
+	}
+	for _, test := range tests {
+		n := LatencyToBytes(test.seconds, test.spec)
+		if n != test.bytes {
+			t.Errorf("LatencyToBytes(%v, %v) = %d, expected %d", test.seconds, test.spec, n, test.bytes)
+		}
+		if s := BytesToLatency(n, test.spec); s != test.seconds {
+			t.Errorf("BytesToLatency(%d, %v) = %v, expected %v", n, test.spec, s, test.seconds)
+		}
+	}
+}
+
+func TestBytesToLatencyPartialFrame(t *testing.T) {
+	spec := proto.SampleSpec{Format: proto.FormatInt16LE, Channels: 2, Rate: 1000}
+	if s := BytesToLatency(7, spec); s != 0.001 {
+		t.Errorf("BytesToLatency(7, %v) = %v, expected 0.001", spec, s)
+	}
+}
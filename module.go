@@ -0,0 +1,46 @@
+package pulse
+
+import "github.com/jfreymuth/pulse/proto"
+
+// ModuleInfo describes a module loaded by the server.
+type ModuleInfo struct {
+	Index    uint32
+	Name     string
+	Argument string
+	// Users is the number of users of the module, or -1 if the module doesn't track its users.
+	Users int
+}
+
+// ListModules returns a list of all modules currently loaded by the server.
+func (c *Client) ListModules() ([]ModuleInfo, error) {
+	var reply proto.GetModuleInfoListReply
+	err := c.c().Request(&proto.GetModuleInfoList{}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	modules := make([]ModuleInfo, len(reply))
+	for i, m := range reply {
+		users := -1
+		if m.Users != proto.Undefined {
+			users = int(m.Users)
+		}
+		modules[i] = ModuleInfo{m.ModuleIndex, m.ModuleName, m.ModuleArgs, users}
+	}
+	return modules, nil
+}
+
+// LoadModule loads a module with the given name and argument string, e.g. LoadModule("module-null-sink", "sink_name=test").
+// The returned index can be used to unload the module again.
+func (c *Client) LoadModule(name, argument string) (uint32, error) {
+	var reply proto.LoadModuleReply
+	err := c.c().Request(&proto.LoadModule{Name: name, Args: argument}, &reply)
+	if err != nil {
+		return 0, err
+	}
+	return reply.ModuleIndex, nil
+}
+
+// UnloadModule unloads the module with the given index.
+func (c *Client) UnloadModule(index uint32) error {
+	return c.c().Request(&proto.UnloadModule{ModuleIndex: index}, nil)
+}
@@ -0,0 +1,56 @@
+package pulse
+
+import (
+	"math"
+	"sync/atomic"
+
+	"github.com/jfreymuth/pulse/proto"
+)
+
+// A PeakDetector measures the level of the audio played by a sink, e.g. for a VU meter.
+// The peak detection is done by the server, so very little data is sent to the client.
+type PeakDetector struct {
+	r    *RecordStream
+	peak uint32 // float32 bits, accessed atomically
+}
+
+// NewPeakDetector creates a peak detector for the sink's monitor source.
+// rate is the number of peak values measured per second, e.g. 25 for a meter that is redrawn at 25 fps.
+// Peak detection doesn't keep the sink from being suspended, in which case the peak stays at its last value.
+func (s *Sink) NewPeakDetector(rate int) (*PeakDetector, error) {
+	d := &PeakDetector{}
+	w := Float32Writer(func(buf []float32) (int, error) {
+		if len(buf) > 0 {
+			atomic.StoreUint32(&d.peak, math.Float32bits(buf[len(buf)-1]))
+		}
+		return len(buf), nil
+	})
+	r, err := s.c.NewRecord(w,
+		RecordMonitor(s),
+		RecordMono,
+		RecordSampleRate(rate),
+		RecordBufferFragmentSize(4),
+		RecordRawOption(func(r *proto.CreateRecordStream) {
+			r.PeakDetect = true
+			r.AdjustLatency = true
+			r.NoMove = true
+			r.DontInhibitAutoSuspend = true
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.r = r
+	r.Start()
+	return d, nil
+}
+
+// Peak returns the most recently measured peak, 0 is silence and 1 is full scale.
+func (d *PeakDetector) Peak() float64 {
+	return float64(math.Float32frombits(atomic.LoadUint32(&d.peak)))
+}
+
+// Close stops the peak detector.
+func (d *PeakDetector) Close() {
+	d.r.Close()
+}
@@ -1,27 +1,69 @@
 package pulse
 
-import "github.com/jfreymuth/pulse/proto"
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jfreymuth/pulse/proto"
+)
 
 // A PlaybackStream is used for playing audio.
 // When creating a stream, the user must provide a callback that will be used to buffer audio data.
+//
+// The methods of a stream may be called from any goroutine while the stream is playing, except for Recreate,
+// which must not be called concurrently with other methods. The callback runs on a separate goroutine; Stop and Pause
+// take effect after the current call returns. Start, Close and Recreate must not be called from the callback.
 type PlaybackStream struct {
 	c *Client
 
 	index     uint32
-	state     streamState
-	underflow bool
-	err       error
+	state     streamState // protected by mu
+	underflow bool        // protected by mu
+	err       error       // protected by mu
 
 	front, back []byte
+	partial     int // bytes of an incomplete frame at the start of front, see run
 	requested   int
-	request     chan int
 	started     chan bool
+	wake        chan struct{}
 
-	r Reader
+	reqMu     sync.Mutex // protects request and reqClosed, see sendRequest
+	request   chan int
+	reqClosed bool
+	exited    chan struct{} // closed when run returns, see Recreate
+
+	r    Reader
+	src  *swappableReader // the reader passed to NewPlayback, see SetCallback
+	next Reader           // protected by mu, replaces src.r before the next read
 
 	createRequest  proto.CreatePlaybackStream
-	createReply    proto.CreatePlaybackStreamReply
+	createReply    proto.CreatePlaybackStreamReply // protected by mu, see reply
 	bytesPerSample int
+
+	adaptive *adaptiveLatency // protected by mu
+	fallback []*Sink
+
+	startVolume float32
+	onDrained   func()            // protected by mu
+	corkCount   int               // protected by mu
+	onCork      func(corked bool) // protected by mu
+	restore     bool
+	strict      bool
+	onUnderflow func()
+	onSuspend   func(suspended bool)
+
+	mu          sync.Mutex
+	done        chan struct{}
+	ended       bool
+	cancelDrain map[int]context.CancelFunc
+	drainID     int
+
+	fadeMu sync.Mutex // serializes volume changes of fades
+	fade   chan struct{}
 }
 
 // EndOfData is a special error value that can be returned by a reader to stop the stream.
@@ -31,14 +73,50 @@ const EndOfData endOfData = false
 // The created stream wil not be running, it must be started with Start().
 // If the reader returns any error, the stream will be stopped. The special error value EndOfData
 // can be used to intentionally stop the stream from within the callback.
+// r may only be nil if the PlaybackConverter option is used.
 // The order of options is important in some cases, see the documentation of the individual PlaybackOptions.
 func (c *Client) NewPlayback(r Reader, opts ...PlaybackOption) (*PlaybackStream, error) {
+	p := c.newPlayback(r, opts)
+	err := p.create()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewPlaybackContext is like NewPlayback, but gives up waiting for the server when the context is done.
+// If the server creates the stream after that, the stream is deleted again.
+func (c *Client) NewPlaybackContext(ctx context.Context, r Reader, opts ...PlaybackOption) (*PlaybackStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p := c.newPlayback(r, opts)
+	done := make(chan error, 1)
+	go func() { done <- p.create() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case <-ctx.Done():
+		go func() {
+			if <-done == nil {
+				p.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// newPlayback applies the options, but doesn't create the stream.
+func (c *Client) newPlayback(r Reader, opts []PlaybackOption) *PlaybackStream {
 	p := &PlaybackStream{
 		c: c,
 		createRequest: proto.CreatePlaybackStream{
 			SinkIndex:             proto.Undefined,
 			ChannelMap:            proto.ChannelMap{proto.ChannelMono},
-			SampleSpec:            proto.SampleSpec{Format: r.Format(), Channels: 1, Rate: 44100},
+			SampleSpec:            proto.SampleSpec{Channels: 1, Rate: 44100},
 			BufferMaxLength:       proto.Undefined,
 			Corked:                true,
 			BufferTargetLength:    proto.Undefined,
@@ -46,13 +124,22 @@ func (c *Client) NewPlayback(r Reader, opts ...PlaybackOption) (*PlaybackStream,
 			BufferMinimumRequest:  proto.Undefined,
 			Properties:            proto.PropList{},
 		},
-		bytesPerSample: bytes(r.Format()),
-		r:              r,
+		r:           r,
+		startVolume: -1,
+	}
+	if r != nil {
+		p.src = &swappableReader{r, r.Format()}
+		p.r = p.src
+		p.createRequest.Format = r.Format()
+		p.bytesPerSample = bytes(r.Format())
 	}
 
 	for _, opt := range opts {
 		opt(p)
 	}
+	if p.r == nil {
+		panic("pulse: no reader")
+	}
 
 	if p.createRequest.ChannelVolumes == nil {
 		cvol := make(proto.ChannelVolumes, len(p.createRequest.ChannelMap))
@@ -61,45 +148,201 @@ func (c *Client) NewPlayback(r Reader, opts ...PlaybackOption) (*PlaybackStream,
 		}
 		p.createRequest.ChannelVolumes = cvol
 	}
+	return p
+}
 
-	err := c.c.Request(&p.createRequest, &p.createReply)
+// create creates the stream on the server and registers it with the client.
+func (p *PlaybackStream) create() error {
+	c := p.c
+	var reply proto.CreatePlaybackStreamReply
+	err := c.c().Request(&p.createRequest, &reply)
+	for i := 0; i < len(p.fallback); i++ {
+		if _, ok := err.(proto.Error); !ok {
+			// only retry if the server refused to create the stream
+			break
+		}
+		p.createRequest.SinkIndex = p.fallback[i].info.SinkIndex
+		p.createRequest.SinkName = ""
+		err = c.c().Request(&p.createRequest, &reply)
+	}
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if p.strict && reply.Format != p.createRequest.Format {
+		c.c().Request(&proto.DeletePlaybackStream{StreamIndex: reply.StreamIndex}, nil)
+		return errFormatNotSupported
 	}
-	p.index = p.createReply.StreamIndex
-	p.front = make([]byte, p.createReply.BufferMaxLength)
-	p.back = make([]byte, p.createReply.BufferMaxLength)
-	p.request = make(chan int)
-	p.started = make(chan bool)
+	p.index = reply.StreamIndex
+	p.mu.Lock()
+	p.createReply = reply
+	p.state = idle
+	p.err = nil
+	p.mu.Unlock()
+	// run reads into these buffers in chunks of at most their size, so it never needs to allocate
+	p.front = make([]byte, reply.BufferMaxLength)
+	p.back = make([]byte, reply.BufferMaxLength)
+	request := make(chan int)
+	p.reqMu.Lock()
+	p.request, p.reqClosed = request, false
+	p.reqMu.Unlock()
+	p.started = make(chan bool, 1)
+	// the previous run loop has returned, see Recreate
+	p.requested, p.partial = 0, 0
+	p.exited = make(chan struct{})
 	c.mu.Lock()
 	c.playback[p.index] = p
 	c.mu.Unlock()
-	go p.run()
-	return p, nil
+	go p.run(request, p.exited)
+	return nil
 }
 
-func (p *PlaybackStream) run() {
-	for n := range p.request {
-		if p.state != running {
+// reply returns a copy of the server's reply to the create request, with the updates the server sent since.
+func (p *PlaybackStream) reply() proto.CreatePlaybackStreamReply {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.createReply
+}
+
+// Recreate deletes the stream on the server and creates it again with the same options and reader.
+// This can be used to recover from errors that can't be fixed otherwise, e.g. when the stream's sink was removed.
+// The reader is not reset, so playback continues where it left off. If the stream was running, it is restarted.
+// The stream will have a new stream index, and adaptive latency will be disabled.
+// Recreate waits for a call of the reader that is in progress to return.
+// If Recreate returns an error, the stream is closed.
+func (p *PlaybackStream) Recreate() error {
+	wasRunning := p.Running()
+	if p.shutdown(closed) {
+		p.c.c().Request(&proto.DeletePlaybackStream{StreamIndex: p.index}, nil)
+	}
+	// the new run loop uses the same buffers and reader
+	<-p.exited
+	if err := p.create(); err != nil {
+		p.mu.Lock()
+		p.state = closed
+		p.mu.Unlock()
+		p.end()
+		return err
+	}
+	if wasRunning {
+		return p.Start()
+	}
+	return nil
+}
+
+// NewPlaybackSpec creates a playback stream that plays raw audio in the format described by spec and m.
+// This is useful if the format is already known, e.g. from a decoder.
+// If m is nil, a mono or stereo channel map is used depending on the number of channels.
+// The options should not change the sample format, sample rate or channels.
+// See NewPlayback for details.
+func (c *Client) NewPlaybackSpec(spec proto.SampleSpec, m proto.ChannelMap, r io.Reader, opts ...PlaybackOption) (*PlaybackStream, error) {
+	if m == nil {
+		m = defaultChannelMap(spec.Channels)
+	}
+	if !validSpec(spec, m) {
+		return nil, errInvalidSampleSpec
+	}
+	opts = append([]PlaybackOption{PlaybackChannels(m), PlaybackSampleRate(int(spec.Rate))}, opts...)
+	return c.NewPlayback(NewReader(r, spec.Format), opts...)
+}
+
+// limits imposed by the server
+const (
+	maxRate     = 8 * 48000
+	maxChannels = 32
+)
+
+const errInvalidSampleSpec = pulseError("pulse: invalid sample spec")
+
+// defaultChannelMap returns a mono or stereo channel map, or nil for other numbers of channels.
+func defaultChannelMap(channels byte) proto.ChannelMap {
+	switch channels {
+	case 1:
+		return proto.ChannelMap{proto.ChannelMono}
+	case 2:
+		return proto.ChannelMap{proto.ChannelLeft, proto.ChannelRight}
+	}
+	return nil
+}
+
+func validSpec(spec proto.SampleSpec, m proto.ChannelMap) bool {
+	return validFormat(spec.Format) && spec.Rate != 0 && spec.Rate <= maxRate &&
+		spec.Channels != 0 && spec.Channels <= maxChannels && len(m) == int(spec.Channels)
+}
+
+// run reads from the reader whenever the server requests data and sends it to the server.
+// The reader may return less data than requested, even incomplete frames. Only whole frames are sent,
+// the rest is kept at the start of the buffer and completed by the next read.
+func (p *PlaybackStream) run(request chan int, exited chan struct{}) {
+	defer close(exited)
+	frame := int(p.reply().Channels) * p.bytesPerSample
+	for n := range request {
+		if !p.Running() {
 			continue
 		}
 		p.requested += n
 		for p.requested > 0 {
-			n, err := p.r.Read(p.front[:p.requested])
+			p.mu.Lock()
+			stopped := p.state != running && p.state != paused
+			p.mu.Unlock()
+			if stopped {
+				// Start flushes the buffer and requests it again
+				p.requested = 0
+				break
+			}
+			n := p.requested
+			if n < p.partial+frame {
+				n = p.partial + frame
+			}
+			if n > len(p.front) {
+				n = len(p.front)
+			}
+			p.swapReader()
+			n, err := p.r.Read(p.front[p.partial:n])
 			if n > 0 {
-				p.c.c.Send(p.index, p.front[:n])
-				p.requested -= n
-				p.front, p.back = p.back, p.front
+				end := p.partial + n
+				whole := end - end%frame
+				p.partial = end - whole
+				if whole > 0 {
+					if serr := p.c.c().Send(p.index, p.front[:whole]); serr != nil {
+						err = serr
+					}
+					p.requested -= whole
+					copy(p.back, p.front[whole:end])
+					p.front, p.back = p.back, p.front
+				}
 			}
 			if err != nil {
+				// an incomplete frame at the end of the data can't be played
+				p.partial = 0
+				p.mu.Lock()
 				if err != EndOfData {
 					p.err = err
 				}
-				p.state = idle
+				if p.state == running {
+					p.state = ended
+				}
+				p.mu.Unlock()
+				p.end()
+				p.notStarted()
 				break
 			}
+			if n == 0 && p.wake != nil {
+				// the reader has no data yet, wait until it has or the server requests more
+				select {
+				case <-p.wake:
+				case n, ok := <-request:
+					if !ok {
+						return
+					}
+					p.requested += n
+				}
+				continue
+			}
 			select {
-			case n = <-p.request:
+			case n, ok := <-request:
+				if !ok {
+					return
+				}
 				p.requested += n
 			default:
 			}
@@ -107,97 +350,928 @@ func (p *PlaybackStream) run() {
 	}
 }
 
-// Start starts playing audio.
-func (p *PlaybackStream) Start() {
-	if p.state == idle {
-		p.c.c.Request(&proto.FlushPlaybackStream{StreamIndex: p.index}, nil)
-		p.state = running
-		p.err = nil
-		p.request <- int(p.createReply.BufferTargetLength)
-		p.underflow = false
-		p.c.c.Request(&proto.CorkPlaybackStream{StreamIndex: p.index, Corked: false}, nil)
-		<-p.started
+// Start starts playing audio and waits until the server has started playback.
+// If the stream can't be started, e.g. because the initial audio couldn't be sent to the server,
+// the stream is stopped and the error is returned. The error is also reported by Error.
+//
+// A stream that has ended because its reader returned an error can be started again.
+// Calling Start on a running stream does nothing. A paused stream must be resumed with Resume instead,
+// Start returns ErrPaused. On a closed stream, Start returns ErrStreamClosed.
+func (p *PlaybackStream) Start() error {
+	return p.start(true)
+}
+
+// start starts the stream, if wait is true it waits until the server has started playback.
+func (p *PlaybackStream) start(wait bool) error {
+	p.mu.Lock()
+	switch p.state {
+	case running:
+		p.mu.Unlock()
+		return nil
+	case paused:
+		p.mu.Unlock()
+		return ErrPaused
+	case closed, serverLost:
+		p.mu.Unlock()
+		return ErrStreamClosed
+	}
+	p.state = running
+	p.err = nil
+	p.underflow = false
+	p.mu.Unlock()
+	select {
+	case <-p.started:
+	default:
+	}
+	if err := p.c.c().Request(&proto.FlushPlaybackStream{StreamIndex: p.index}, nil); err != nil {
+		p.failStart(err)
+		return err
+	}
+	if p.startVolume >= 0 {
+		if err := p.setVolume(float64(p.startVolume)); err != nil {
+			p.failStart(err)
+			return err
+		}
+	}
+	if err := p.c.c().Request(&proto.CorkPlaybackStream{StreamIndex: p.index, Corked: false}, nil); err != nil {
+		p.failStart(err)
+		return err
+	}
+	// only fill the buffer once the stream is known to be started, so a failed start doesn't call the reader
+	p.sendRequest(int(p.reply().BufferTargetLength))
+	if wait && !<-p.started {
+		if err := p.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failStart stops a stream that couldn't be started.
+func (p *PlaybackStream) failStart(err error) {
+	p.mu.Lock()
+	if p.state == running {
+		p.state = idle
+	}
+	p.err = err
+	p.mu.Unlock()
+}
+
+// sendRequest passes a request for n bytes to the run loop, unless the stream was closed.
+// Sending and closing the request channel are synchronized so that closing a stream from any goroutine is safe.
+func (p *PlaybackStream) sendRequest(n int) {
+	p.reqMu.Lock()
+	if !p.reqClosed {
+		p.request <- n
+	}
+	p.reqMu.Unlock()
+}
+
+// shutdown switches the stream to the final state s and stops the run loop.
+// It returns false if the stream was already closed.
+func (p *PlaybackStream) shutdown(s streamState) bool {
+	p.mu.Lock()
+	if p.state == closed || p.state == serverLost {
+		p.mu.Unlock()
+		return false
+	}
+	p.state = s
+	p.mu.Unlock()
+	p.DisableAdaptiveLatency()
+	p.reqMu.Lock()
+	if !p.reqClosed {
+		close(p.request)
+		p.reqClosed = true
+	}
+	p.reqMu.Unlock()
+	p.c.mu.Lock()
+	if p.c.playback[p.index] == p {
+		delete(p.c.playback, p.index)
+	}
+	p.c.mu.Unlock()
+	p.notStarted()
+	return true
+}
+
+// lost marks the stream as lost with the connection to the server.
+func (p *PlaybackStream) lost() {
+	p.mu.Lock()
+	p.restore = p.state == running
+	if p.state != closed && p.state != serverLost {
+		p.err = ErrConnectionClosed
+	}
+	p.mu.Unlock()
+	p.shutdown(serverLost)
+}
+
+// serverStarted is called when the server reports that playback has started.
+func (p *PlaybackStream) serverStarted() {
+	p.mu.Lock()
+	ok := p.state == running && !p.underflow
+	p.mu.Unlock()
+	if ok {
+		select {
+		case p.started <- true:
+		default:
+		}
+	}
+}
+
+// serverUnderflow is called when the server reports an underflow.
+func (p *PlaybackStream) serverUnderflow() {
+	p.mu.Lock()
+	if p.state == running {
+		p.underflow = true
+	}
+	a := p.adaptive
+	p.mu.Unlock()
+	if a != nil {
+		a.underflow()
+	}
+	if p.onUnderflow != nil {
+		p.onUnderflow()
+	}
+}
+
+// serverCork is called when the server asks the stream to be corked or uncorked, see OnCorkChanged.
+func (p *PlaybackStream) serverCork(corked bool) {
+	p.mu.Lock()
+	onCork := p.onCork
+	p.mu.Unlock()
+	if onCork != nil {
+		go onCork(corked)
+	}
+}
+
+// notStarted wakes up a call to Start that is waiting for the server, because the stream has stopped.
+func (p *PlaybackStream) notStarted() {
+	select {
+	case p.started <- false:
+	default:
 	}
 }
 
 // Stop stops playing audio; the callback will no longer be called.
 // If the buffer size/latency is large, audio may continue to play for some time after the call to Stop.
+// If a function was set with OnDrained, Stop starts a drain in the background, which calls it once that audio has been played.
 func (p *PlaybackStream) Stop() {
+	p.mu.Lock()
+	drain := p.state == running && p.onDrained != nil
 	if p.state == running || p.state == paused {
 		p.state = idle
 	}
+	p.mu.Unlock()
+	if drain {
+		go p.drain()
+	}
 }
 
 // Pause stops playing audio immediately.
 func (p *PlaybackStream) Pause() {
-	if p.state == running {
-		p.c.c.Request(&proto.CorkPlaybackStream{StreamIndex: p.index, Corked: true}, nil)
-		p.state = paused
+	p.mu.Lock()
+	if p.state != running {
+		p.mu.Unlock()
+		return
+	}
+	p.state = paused
+	p.corkCount++
+	for id, cancel := range p.cancelDrain {
+		cancel()
+		delete(p.cancelDrain, id)
 	}
+	p.mu.Unlock()
+	p.c.c().Request(&proto.CorkPlaybackStream{StreamIndex: p.index, Corked: true}, nil)
 }
 
-// Resume resumes a paused stream.
+// Resume resumes a paused stream. It does nothing if the stream is not paused,
+// in particular it doesn't restart a stream that has ended.
 func (p *PlaybackStream) Resume() {
-	if p.state == paused {
-		p.c.c.Request(&proto.CorkPlaybackStream{StreamIndex: p.index, Corked: false}, nil)
-		p.state = running
-		p.underflow = false
+	p.mu.Lock()
+	if p.state != paused {
+		p.mu.Unlock()
+		return
+	}
+	p.state = running
+	p.underflow = false
+	p.corkCount++
+	p.mu.Unlock()
+	p.c.c().Request(&proto.CorkPlaybackStream{StreamIndex: p.index, Corked: false}, nil)
+}
+
+// Flush discards the audio buffered by the server, without stopping the stream.
+// The server will request new data immediately, so this can be used to make a seek audible without delay.
+// Audio that was already sent to the device can't be discarded, so a few milliseconds of old audio may still be heard.
+// If the stream uses prebuffering, playback resumes once the prebuffer is filled again.
+func (p *PlaybackStream) Flush() error {
+	if p.Closed() {
+		return ErrStreamClosed
+	}
+	return p.c.c().Request(&proto.FlushPlaybackStream{StreamIndex: p.index}, nil)
+}
+
+// Trigger starts playback immediately, even if the server is still waiting for the prebuffer to be filled,
+// see PlaybackPrebuffer. This also applies when the server waits for the prebuffer after an underflow.
+//
+// Trigger doesn't uncork the stream: a paused stream stays paused until Resume is called. Together with a large
+// prebuffer, Trigger allows starting several streams at nearly the same time, once all of them have received enough data.
+func (p *PlaybackStream) Trigger() error {
+	if p.Closed() {
+		return ErrStreamClosed
+	}
+	return p.c.c().Request(&proto.TriggerPlaybackStream{StreamIndex: p.index}, nil)
+}
+
+// SetCallback replaces the reader passed to NewPlayback, e.g. to continue with the next track without a gap.
+// The new reader must use the same format. It is used from the next time the stream requests data,
+// an incomplete frame returned by the old reader is discarded. SetCallback may be called from the callback.
+// If the stream has ended because the old reader returned an error, it must be started again.
+func (p *PlaybackStream) SetCallback(r Reader) error {
+	if p.src == nil {
+		return errNoCallback
 	}
+	if r.Format() != p.src.f {
+		return errCallbackFormat
+	}
+	p.mu.Lock()
+	p.next = r
+	p.mu.Unlock()
+	return nil
+}
+
+// swapReader applies a pending SetCallback, it must only be called by run.
+func (p *PlaybackStream) swapReader() {
+	p.mu.Lock()
+	next := p.next
+	p.next = nil
+	p.mu.Unlock()
+	if next != nil {
+		p.src.r = next
+		p.partial = 0
+	}
+}
+
+// swappableReader forwards to a reader that can be replaced by SetCallback.
+// The format is stored separately, so the options that wrap the reader don't race with SetCallback.
+type swappableReader struct {
+	r Reader
+	f byte
 }
 
+func (s *swappableReader) Read(buf []byte) (int, error) { return s.r.Read(buf) }
+func (s *swappableReader) Format() byte                 { return s.f }
+
+const (
+	errNoCallback     = pulseError("pulse: stream has no reader to replace")
+	errCallbackFormat = pulseError("pulse: reader format does not match the stream")
+)
+
 // Drain waits until the playback has ended.
-// Drain does not return when the stream is paused.
+// If the stream is paused while Drain is waiting, Drain returns.
 func (p *PlaybackStream) Drain() {
-	if p.state == running {
-		p.c.c.Request(&proto.DrainPlaybackStream{StreamIndex: p.index}, nil)
+	p.DrainContext(context.Background())
+}
+
+// DrainContext waits until the playback has ended, the context is done, or the stream is paused.
+// If the stream is paused, DrainContext returns ErrPaused. If the stream is not running, it returns immediately.
+// Cancelling the context doesn't affect the stream.
+func (p *PlaybackStream) DrainContext(ctx context.Context) error {
+	p.mu.Lock()
+	state := p.state
+	p.mu.Unlock()
+	switch state {
+	case paused:
+		return ErrPaused
+	case running:
+		return p.drainContext(ctx)
 	}
+	return nil
 }
 
-// Close closes the stream.
-func (p *PlaybackStream) Close() {
-	if !p.Closed() {
-		p.c.c.Request(&proto.DeletePlaybackStream{StreamIndex: p.index}, nil)
-		p.state = closed
-		close(p.request)
-		p.c.mu.Lock()
-		delete(p.c.playback, p.index)
-		p.c.mu.Unlock()
+func (p *PlaybackStream) drain() {
+	p.drainContext(context.Background())
+}
+
+func (p *PlaybackStream) drainContext(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p.mu.Lock()
+	if p.state == paused {
+		// checked with the lock held, so Pause can't miss the drain, see cancelDrain
+		p.mu.Unlock()
+		return ErrPaused
+	}
+	if p.cancelDrain == nil {
+		p.cancelDrain = make(map[int]context.CancelFunc)
 	}
+	id := p.drainID
+	p.drainID++
+	p.cancelDrain[id] = cancel
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.cancelDrain, id)
+		p.mu.Unlock()
+	}()
+
+	err := p.c.c().RequestContext(ctx, &proto.DrainPlaybackStream{StreamIndex: p.index}, nil)
+	if err != nil {
+		if p.paused() {
+			return ErrPaused
+		}
+		return err
+	}
+	p.mu.Lock()
+	onDrained := p.onDrained
+	p.mu.Unlock()
+	if onDrained != nil {
+		onDrained()
+	}
+	return nil
+}
+
+// ErrPaused is returned by DrainContext if the stream was paused while draining, and by Start if the stream is paused.
+const ErrPaused = pulseError("pulse: stream paused")
+
+// OnDrained sets a function that is called when a drain started by Drain or Stop has completed,
+// i.e. when the last sample has been played. Passing nil removes the function.
+// When the drain was started by Stop, the function is called on a separate goroutine.
+func (p *PlaybackStream) OnDrained(f func()) {
+	p.mu.Lock()
+	p.onDrained = f
+	p.mu.Unlock()
+}
+
+// FeedSilence sends d of silence to the server without calling the stream's reader.
+// This can be used to keep a stream from underflowing during gaps in the audio.
+// The silence counts against the server-side buffer, so the reader will be asked for correspondingly less data.
+func (p *PlaybackStream) FeedSilence(d time.Duration) error {
+	r := p.reply()
+	frame := int(r.Channels) * p.bytesPerSample
+	n := int(d.Seconds()*float64(r.Rate)) * frame
+	chunk := int(r.BufferMaxLength)
+	chunk -= chunk % frame
+	if chunk <= 0 || chunk > n {
+		chunk = n
+	}
+	buf := make([]byte, chunk)
+	if s := silence(r.Format); s != 0 {
+		for i := range buf {
+			buf[i] = s
+		}
+	}
+	for n > 0 {
+		if chunk > n {
+			chunk = n
+		}
+		if err := p.c.c().Send(p.index, buf[:chunk]); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// SendAt sends buf to the server so that playback of its first sample starts at time t.
+// buf must contain whole frames in the stream's format. It is sent in addition to the data provided by the reader,
+// so it is most useful for streams whose reader only provides silence.
+//
+// The PulseAudio protocol has no presentation timestamps; SendAt emulates them by measuring the current latency and
+// inserting silence before buf. Accuracy depends on the latency reported by the server, synchronizing multiple
+// devices additionally requires their clocks to be synchronized. If t has already passed or the latency cannot be
+// determined, buf is sent immediately.
+func (p *PlaybackStream) SendAt(buf []byte, t time.Time) error {
+	if d, err := p.latency(); err == nil {
+		if gap := time.Until(t) - d; gap > 0 {
+			if err := p.FeedSilence(gap); err != nil {
+				return err
+			}
+		}
+	}
+	chunk := int(p.reply().BufferMaxLength)
+	for len(buf) > 0 {
+		n := len(buf)
+		if chunk > 0 && n > chunk {
+			n = chunk
+		}
+		if err := p.c.c().Send(p.index, buf[:n]); err != nil {
+			return err
+		}
+		buf = buf[n:]
+	}
+	return nil
+}
+
+// Latency returns the time it will take until audio written to the stream now is heard.
+// It is calculated from the amount of audio buffered by the server and the latency of the sink,
+// corrected for the time it took to receive the reply.
+// If the stream is not running, Latency returns 0.
+func (p *PlaybackStream) Latency() (time.Duration, error) {
+	if !p.Running() {
+		return 0, nil
+	}
+	return p.latency()
+}
+
+// latency returns the time it will take until audio sent now is played.
+func (p *PlaybackStream) latency() (time.Duration, error) {
+	var reply proto.GetPlaybackLatencyReply
+	start := time.Now()
+	err := p.c.c().Request(&proto.GetPlaybackLatency{StreamIndex: p.index}, &reply)
+	if err != nil {
+		return 0, err
+	}
+	// the values were measured by the server about half a round trip ago
+	transport := time.Since(start) / 2
+	queued := int(reply.WriteIndex - reply.ReadIndex)
+	if queued < 0 {
+		queued = 0
+	}
+	buffer := BytesToLatency(queued, p.reply().SampleSpec)
+	l := time.Duration(reply.Latency)*time.Microsecond + time.Duration(buffer*float64(time.Second)) - transport
+	if l < 0 {
+		l = 0
+	}
+	return l, nil
+}
+
+// Close closes the stream and waits until the server has deleted it.
+// The stream is closed even if the server reports an error, which is returned.
+// Calling Close on a closed stream does nothing and returns nil.
+func (p *PlaybackStream) Close() error {
+	if !p.shutdown(closed) {
+		return nil
+	}
+	err := p.c.c().Request(&proto.DeletePlaybackStream{StreamIndex: p.index}, nil)
+	p.end()
+	return err
+}
+
+// Done returns a channel that is closed when the stream has ended, i.e. when the reader returned an error
+// (including EndOfData), or the stream was closed.
+// The channel is only closed once, restarting the stream does not reset it.
+// Use Error to find out why the stream ended.
+func (p *PlaybackStream) Done() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done == nil {
+		p.done = make(chan struct{})
+		if p.ended {
+			close(p.done)
+		}
+	}
+	return p.done
+}
+
+// end marks the stream as ended, see Done.
+func (p *PlaybackStream) end() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.ended {
+		p.ended = true
+		if p.done != nil {
+			close(p.done)
+		}
+	}
+}
+
+// Detach removes the stream from the client without deleting it on the server, and returns its stream index.
+// The stream can then be managed by other code using (*Client).RawRequest.
+// After Detach, the reader will no longer be called and the PlaybackStream behaves like a closed stream.
+func (p *PlaybackStream) Detach() uint32 {
+	if p.shutdown(closed) {
+		p.end()
+	}
+	return p.index
 }
 
 // Closed returns wether the stream was closed.
-func (p *PlaybackStream) Closed() bool { return p.state == closed || p.state == serverLost }
+func (p *PlaybackStream) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state == closed || p.state == serverLost
+}
 
 // Running returns wether the stream is currently playing.
-func (p *PlaybackStream) Running() bool { return p.state == running }
+func (p *PlaybackStream) Running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state == running
+}
+
+func (p *PlaybackStream) paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state == paused
+}
 
 // Underflow returns true if any underflows happend since the last call to Start or Resume.
 // Underflows usually happen because the latency/buffer size is too low or because the callback
 // takes too long to run.
-func (p *PlaybackStream) Underflow() bool { return p.underflow }
+func (p *PlaybackStream) Underflow() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.underflow
+}
+
+// OnCorkChanged sets a function that is called when the server asks the stream to be paused or resumed,
+// e.g. because module-role-cork corks music streams during a phone call.
+// The server does not pause the stream by itself; the function should call Pause or Resume if the application agrees.
+// It is not called for Pause and Resume calls made by the application.
+// The function is called on a separate goroutine.
+func (p *PlaybackStream) OnCorkChanged(f func(corked bool)) {
+	p.mu.Lock()
+	p.onCork = f
+	p.mu.Unlock()
+}
+
+// CorkCount returns how often the stream was paused or resumed.
+// A rapidly increasing count can indicate that something is repeatedly pausing and resuming the stream.
+func (p *PlaybackStream) CorkCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.corkCount
+}
 
 // Error returns the last error returned by the stream's reader.
-func (p *PlaybackStream) Error() error { return p.err }
+func (p *PlaybackStream) Error() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
 
 // SampleRate returns the stream's sample rate (samples per second).
 func (p *PlaybackStream) SampleRate() int {
-	return int(p.createReply.Rate)
+	return int(p.reply().Rate)
 }
 
 // Channels returns the number of channels.
 func (p *PlaybackStream) Channels() int {
-	return int(p.createReply.Channels)
+	return int(p.reply().Channels)
+}
+
+// SampleSpec returns the stream's sample format, rate and number of channels.
+func (p *PlaybackStream) SampleSpec() proto.SampleSpec {
+	return p.reply().SampleSpec
+}
+
+// Format returns the sample format the server uses for the stream, e.g. proto.FormatInt16LE.
+func (p *PlaybackStream) Format() byte {
+	return p.reply().Format
 }
 
-// BufferSize returns the size of the server-side buffer in samples.
+// BytesPerSample returns the size of a single sample of one channel in bytes.
+func (p *PlaybackStream) BytesPerSample() int {
+	return p.bytesPerSample
+}
+
+// BufferSize returns the size of the server-side buffer in samples per channel.
 func (p *PlaybackStream) BufferSize() int {
-	s := int(p.createReply.BufferTargetLength) / int(p.createReply.Channels)
-	return s / p.bytesPerSample
+	r := p.reply()
+	frame := int(r.Channels) * p.bytesPerSample
+	return int(r.BufferTargetLength) / frame
 }
 
 // BufferSizeBytes returns the size of the server-side buffer in bytes.
 func (p *PlaybackStream) BufferSizeBytes() int {
-	return int(p.createReply.BufferTargetLength)
+	return int(p.reply().BufferTargetLength)
+}
+
+// TargetLatency returns the latency the server settled on when the stream was created,
+// i.e. the duration of the server-side buffer plus the latency of the sink.
+// Unlike Latency, it doesn't query the server, so it doesn't reflect how full the buffer currently is.
+func (p *PlaybackStream) TargetLatency() time.Duration {
+	r := p.reply()
+	buffer := BytesToLatency(int(r.BufferTargetLength), r.SampleSpec)
+	return time.Duration(r.SinkLatency)*time.Microsecond + time.Duration(buffer*float64(time.Second))
+}
+
+// SetVolume sets the volume of all channels of the stream.
+// A volume of 0 is muted, 1 is normal volume (100%), higher values amplify the audio.
+// A fade started with FadeVolume is stopped.
+func (p *PlaybackStream) SetVolume(v float64) error {
+	p.stopFade()
+	return p.setVolume(v)
+}
+
+// fadeInterval is the time between volume changes during a fade.
+const fadeInterval = 20 * time.Millisecond
+
+// FadeVolume changes the volume of all channels to target in small steps over the duration d.
+// The fade runs in the background, FadeVolume returns immediately.
+// A fade is stopped by another call to FadeVolume, by SetVolume, SetChannelVolumes or SetBalance, and when the stream is closed.
+func (p *PlaybackStream) FadeVolume(target float64, d time.Duration) error {
+	from, err := p.Volume()
+	if err != nil {
+		return err
+	}
+	p.stopFade()
+	if d < fadeInterval {
+		return p.setVolume(target)
+	}
+	stop := make(chan struct{})
+	p.fadeMu.Lock()
+	p.fade = stop
+	p.fadeMu.Unlock()
+	done := p.Done()
+	go func() {
+		t := time.NewTicker(fadeInterval)
+		defer t.Stop()
+		start := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-done:
+				return
+			case <-t.C:
+			}
+			v, last := target, true
+			if elapsed := time.Since(start); elapsed < d {
+				v, last = from+(target-from)*float64(elapsed)/float64(d), false
+			}
+			p.fadeMu.Lock()
+			select {
+			case <-stop:
+				// superseded while waiting for the lock
+				last = true
+			default:
+				if p.setVolume(v) != nil {
+					last = true
+				}
+			}
+			p.fadeMu.Unlock()
+			if last {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// stopFade stops the current fade, if any.
+// Once it returns, the fade will not change the volume anymore.
+func (p *PlaybackStream) stopFade() {
+	p.fadeMu.Lock()
+	if p.fade != nil {
+		close(p.fade)
+		p.fade = nil
+	}
+	p.fadeMu.Unlock()
+}
+
+// Volume returns the volume of the stream. If the channels have different volumes, the highest one is returned.
+// See SetVolume for the volume scale.
+func (p *PlaybackStream) Volume() (float64, error) {
+	info, err := p.sinkInputInfo()
+	if err != nil {
+		return 0, err
+	}
+	return maxVolume(info.ChannelVolumes), nil
+}
+
+// SetChannelVolumes sets the volume of each channel, in the order of the stream's channel map.
+// See SetVolume for the volume scale.
+func (p *PlaybackStream) SetChannelVolumes(v []float64) error {
+	r := p.reply()
+	if len(v) != int(r.Channels) {
+		return fmt.Errorf("pulse: got %d volumes for a stream with %d channels", len(v), r.Channels)
+	}
+	p.stopFade()
+	cvol := make(proto.ChannelVolumes, len(v))
+	for i := range v {
+		cvol[i] = toVolume(v[i])
+	}
+	return p.c.c().Request(&proto.SetSinkInputVolume{
+		SinkInputIndex: r.SinkInputIndex,
+		ChannelVolumes: cvol,
+	}, nil)
+}
+
+// ChannelVolumes returns the volume of each channel, in the order of the stream's channel map.
+// See SetVolume for the volume scale.
+func (p *PlaybackStream) ChannelVolumes() ([]float64, error) {
+	info, err := p.sinkInputInfo()
+	if err != nil {
+		return nil, err
+	}
+	v := make([]float64, len(info.ChannelVolumes))
+	for i, cv := range info.ChannelVolumes {
+		v[i] = fromVolume(cv)
+	}
+	return v, nil
+}
+
+// SetBalance sets the balance of a stereo stream, -1 plays only on the left channel, 1 only on the right channel,
+// and 0 plays both channels at the same volume. The volume of the louder channel is kept.
+// An error is returned if the stream doesn't have exactly a left and a right channel.
+func (p *PlaybackStream) SetBalance(b float64) error {
+	v, err := p.stereoVolumes()
+	if err != nil {
+		return err
+	}
+	if b < -1 {
+		b = -1
+	} else if b > 1 {
+		b = 1
+	}
+	max := v[0]
+	if v[1] > max {
+		max = v[1]
+	}
+	v[0], v[1] = max, max
+	if b < 0 {
+		v[1] *= 1 + b
+	} else {
+		v[0] *= 1 - b
+	}
+	return p.SetChannelVolumes(v)
+}
+
+// Balance returns the balance of a stereo stream, see SetBalance.
+func (p *PlaybackStream) Balance() (float64, error) {
+	v, err := p.stereoVolumes()
+	if err != nil {
+		return 0, err
+	}
+	l, r := v[0], v[1]
+	switch {
+	case l == r:
+		return 0, nil
+	case l > r:
+		return r/l - 1, nil
+	default:
+		return 1 - l/r, nil
+	}
+}
+
+// stereoVolumes returns the volumes of the left and right channel.
+func (p *PlaybackStream) stereoVolumes() ([]float64, error) {
+	m := p.reply().ChannelMap
+	if len(m) != 2 || m[0] != proto.ChannelLeft || m[1] != proto.ChannelRight {
+		return nil, errNotStereo
+	}
+	return p.ChannelVolumes()
+}
+
+const errNotStereo = pulseError("pulse: stream is not a stereo stream")
+
+// SetMute mutes or unmutes the stream. Muting doesn't change the stream's volume.
+// This also works while the stream is paused or not started.
+func (p *PlaybackStream) SetMute(mute bool) error {
+	return p.c.c().Request(&proto.SetSinkInputMute{SinkInputIndex: p.reply().SinkInputIndex, Mute: mute}, nil)
+}
+
+// Muted returns whether the stream is muted.
+func (p *PlaybackStream) Muted() (bool, error) {
+	info, err := p.sinkInputInfo()
+	if err != nil {
+		return false, err
+	}
+	return info.Muted, nil
+}
+
+// MoveTo moves the stream to a different sink, without stopping playback.
+// Depending on the sinks, a short gap or glitch may be audible while the buffered audio is transferred.
+// If the server refuses to move the stream, e.g. because the sink doesn't support the stream's format,
+// the server's error is returned and the stream keeps playing on the previous sink.
+func (p *PlaybackStream) MoveTo(sink *Sink) error {
+	err := p.c.c().Request(&proto.MoveSinkInput{
+		SinkInputIndex: p.reply().SinkInputIndex,
+		DeviceIndex:    sink.info.SinkIndex,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.createReply.SinkIndex = sink.info.SinkIndex
+	p.createReply.SinkName = sink.info.SinkName
+	p.mu.Unlock()
+	return nil
+}
+
+// moved updates the stream after the server moved it to a different sink.
+func (p *PlaybackStream) moved(msg *proto.PlaybackStreamMoved) {
+	p.mu.Lock()
+	p.createReply.SinkIndex = msg.DestIndex
+	p.createReply.SinkName = msg.DestName
+	p.createReply.BufferMaxLength = msg.BufferMaxLength
+	p.createReply.BufferTargetLength = msg.BufferTargetLength
+	p.createReply.BufferPrebufferLength = msg.BufferPrebufferLength
+	p.createReply.BufferMinimumRequest = msg.BufferMinimumRequest
+	p.createReply.SinkLatency = msg.SinkLatency
+	p.mu.Unlock()
+	p.suspended(msg.Suspended)
+}
+
+// suspended updates the stream after the server reported that its sink was suspended or resumed.
+func (p *PlaybackStream) suspended(suspended bool) {
+	p.mu.Lock()
+	changed := p.createReply.SinkSuspended != suspended
+	p.createReply.SinkSuspended = suspended
+	p.mu.Unlock()
+	if changed && p.onSuspend != nil {
+		go p.onSuspend(suspended)
+	}
+}
+
+// Suspended returns whether the stream's sink is suspended, as last reported by the server.
+// While the sink is suspended, the stream doesn't play and the reader is not called, even if Running returns true.
+func (p *PlaybackStream) Suspended() bool {
+	return p.reply().SinkSuspended
+}
+
+// SetProperty sets a property of the stream, e.g. "media.name".
+// Volume control applications will usually pick up the changes immediately.
+func (p *PlaybackStream) SetProperty(key, value string) error {
+	return p.SetProperties(map[string]string{key: value})
+}
+
+// SetProperties sets multiple properties of the stream. Properties that are not mentioned are not changed.
+func (p *PlaybackStream) SetProperties(props map[string]string) error {
+	pl := make(proto.PropList, len(props))
+	for k, v := range props {
+		pl[k] = proto.PropListString(v)
+	}
+	return p.c.c().Request(&proto.UpdatePlaybackStreamProplist{
+		StreamIndex: p.index,
+		Mode:        proto.UpdateReplace,
+		Properties:  pl,
+	}, nil)
+}
+
+// RemoveProperty removes a property of the stream.
+func (p *PlaybackStream) RemoveProperty(key string) error {
+	return p.c.c().Request(&proto.RemovePlaybackStreamProplist{StreamIndex: p.index, Keys: []string{key}}, nil)
+}
+
+// sinkInputInfo returns the server's information about the stream.
+func (p *PlaybackStream) sinkInputInfo() (*proto.GetSinkInputInfoReply, error) {
+	var info proto.GetSinkInputInfoReply
+	err := p.c.c().Request(&proto.GetSinkInputInfo{SinkInputIndex: p.reply().SinkInputIndex}, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// setVolume sets the volume of all channels.
+func (p *PlaybackStream) setVolume(v float64) error {
+	r := p.reply()
+	return p.c.c().Request(&proto.SetSinkInputVolume{
+		SinkInputIndex: r.SinkInputIndex,
+		ChannelVolumes: channelVolumes(int(r.Channels), v),
+	}, nil)
+}
+
+// SetBufferAttr changes the target buffer size, prebuffer size and minimum request size of the stream,
+// in samples per channel like BufferSize and PlaybackBufferSize. A negative value lets the server choose.
+// The server may round or otherwise adjust the values, BufferSize reports the size that is actually used.
+func (p *PlaybackStream) SetBufferAttr(target, prebuf, minreq int) error {
+	r := p.reply()
+	frame := int(r.Channels) * p.bytesPerSample
+	length := func(samples int) uint32 {
+		if samples < 0 {
+			return proto.Undefined
+		}
+		return uint32(samples * frame)
+	}
+	maxLength := r.BufferMaxLength
+	if t := length(target); t != proto.Undefined && maxLength < 2*t {
+		maxLength = 2 * t
+	}
+	return p.setBufferAttr(maxLength, length(target), length(prebuf), length(minreq))
+}
+
+// setBufferAttr changes the server-side buffer attributes and updates the cached values from the server's reply.
+// Lengths are in bytes, proto.Undefined lets the server choose.
+func (p *PlaybackStream) setBufferAttr(maxLength, target, prebuf, minreq uint32) error {
+	var reply proto.SetPlaybackStreamBufferAttrReply
+	err := p.c.c().Request(&proto.SetPlaybackStreamBufferAttr{
+		StreamIndex:           p.index,
+		BufferMaxLength:       maxLength,
+		BufferTargetLength:    target,
+		BufferPrebufferLength: prebuf,
+		BufferMinimumRequest:  minreq,
+		AdjustLatency:         p.createRequest.AdjustLatency,
+	}, &reply)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.createReply.BufferMaxLength = reply.BufferMaxLength
+	p.createReply.BufferTargetLength = reply.BufferTargetLength
+	p.createReply.BufferPrebufferLength = reply.BufferPrebufferLength
+	p.createReply.BufferMinimumRequest = reply.BufferMinimumRequest
+	p.createReply.SinkLatency = reply.SinkLatency
+	p.mu.Unlock()
+	return nil
+}
+
+// TransportMode returns how audio data is transferred to the server.
+// This is always "socket": the data is copied over the connection, shared memory transports (shm, memfd)
+// are not supported by this package.
+func (p *PlaybackStream) TransportMode() string {
+	return "socket"
 }
 
 // StreamIndex returns the stream index.
@@ -206,8 +1280,49 @@ func (p *PlaybackStream) StreamIndex() uint32 {
 	return p.index
 }
 
+// Name returns the stream's effective media name, which may differ from the one set with PlaybackMediaName.
+// If the server can't be queried, the requested name is returned.
+func (p *PlaybackStream) Name() string {
+	var info proto.GetSinkInputInfoReply
+	err := p.c.c().Request(&proto.GetSinkInputInfo{SinkInputIndex: p.reply().SinkInputIndex}, &info)
+	if err == nil {
+		return info.MediaName
+	}
+	if name, ok := p.createRequest.Properties["media.name"]; ok {
+		return name.String()
+	}
+	return p.c.props["media.name"].String()
+}
+
+// Sink returns the sink the stream is playing to.
+func (p *PlaybackStream) Sink() (*Sink, error) {
+	sink := Sink{c: p.c}
+	err := p.c.c().Request(&proto.GetSinkInfo{SinkIndex: p.reply().SinkIndex}, &sink.info)
+	if err != nil {
+		return nil, err
+	}
+	return &sink, nil
+}
+
+// SinkSuspended returns whether the sink the stream plays to is currently suspended.
+// Suspended sinks don't play any audio, the stream may need to be restarted once the sink is resumed.
+func (p *PlaybackStream) SinkSuspended() (bool, error) {
+	sink, err := p.Sink()
+	if err != nil {
+		return false, err
+	}
+	return sink.info.State == sinkStateSuspended, nil
+}
+
+// sink states as reported by the server
+const (
+	sinkStateRunning   = 0
+	sinkStateIdle      = 1
+	sinkStateSuspended = 2
+)
+
 func (p *PlaybackStream) StreamInputIndex() uint32 {
-	return p.createReply.SinkInputIndex
+	return p.reply().SinkInputIndex
 }
 
 // A PlaybackOption supplies configuration when creating streams.
@@ -243,17 +1358,196 @@ func PlaybackSampleRate(rate int) PlaybackOption {
 	}
 }
 
-// PlaybackBufferSize sets the size of the server-side buffer.
+// PlaybackBufferSize sets the size of the server-side buffer, in samples per channel like BufferSize.
 // Setting the buffer size too small causes underflows, resulting in audible artifacts.
 //
+// This should be set after channel options.
+//
 // Buffer size and latency should not be set at the same time.
 func PlaybackBufferSize(samples int) PlaybackOption {
 	return func(p *PlaybackStream) {
-		p.createRequest.BufferTargetLength = uint32(samples * p.bytesPerSample)
+		p.createRequest.BufferTargetLength = p.samplesToBytes(samples)
 		p.createRequest.AdjustLatency = false
 	}
 }
 
+// PlaybackPrebuffer sets the amount of audio the server buffers before it starts playing, in samples like PlaybackBufferSize.
+// Playback also waits for the prebuffer to be filled again after an underflow.
+// A value of 0 disables prebuffering, the stream then starts immediately even if the buffer is empty.
+//
+// This should be set after channel options. If the latency is set with PlaybackLatency, the server may adjust this value.
+func PlaybackPrebuffer(samples int) PlaybackOption {
+	return func(p *PlaybackStream) {
+		p.createRequest.BufferPrebufferLength = p.samplesToBytes(samples)
+	}
+}
+
+// PlaybackMinimumRequest sets the minimum amount of audio the server requests at once, in samples like PlaybackBufferSize.
+// Larger values mean fewer, but larger, calls to the reader.
+//
+// This should be set after channel options. If the latency is set with PlaybackLatency, the server may adjust this value.
+func PlaybackMinimumRequest(samples int) PlaybackOption {
+	return func(p *PlaybackStream) {
+		p.createRequest.BufferMinimumRequest = p.samplesToBytes(samples)
+	}
+}
+
+// samplesToBytes converts a number of samples per channel to bytes, using the channels set by the options so far.
+func (p *PlaybackStream) samplesToBytes(samples int) uint32 {
+	return uint32(samples * int(p.createRequest.Channels) * p.bytesPerSample)
+}
+
+// PlaybackConverter provides the stream's audio using a custom conversion function, instead of the reader passed to NewPlayback.
+// This can be used to play sample types that have no corresponding Reader type.
+//
+// fill must fill the entire buffer with samples in the given format, which must be one of the formats
+// defined by the proto package. It is responsible for the byte order of multi-byte formats.
+// bytesPerSample must be the size of a single sample of one channel in that format.
+// The buffer is only valid until fill returns. If fill returns an error, the buffer is discarded and the stream
+// is stopped, see NewPlayback.
+//
+// This option should be passed before options that set the latency.
+func PlaybackConverter(format byte, bytesPerSample int, fill func(buf []byte) error) PlaybackOption {
+	check(format)
+	if bytesPerSample != bytes(format) {
+		panic("pulse: wrong sample size for format")
+	}
+	return func(p *PlaybackStream) {
+		p.r = converter{format, fill}
+		p.src = nil
+		p.createRequest.Format = format
+		p.bytesPerSample = bytesPerSample
+	}
+}
+
+type converter struct {
+	f    byte
+	fill func([]byte) error
+}
+
+func (c converter) Read(buf []byte) (int, error) {
+	if err := c.fill(buf); err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+func (c converter) Format() byte { return c.f }
+
+// PlaybackFormat24 sends the audio to the server as packed 24 bit samples.
+// The reader passed to NewPlayback must be an Int32Reader, and provide samples in the range of a 24 bit integer,
+// i.e. from -8388608 to 8388607. Higher bits are discarded.
+//
+// This option should be passed before options that set the latency or buffer size.
+var PlaybackFormat24 PlaybackOption = func(p *PlaybackStream) {
+	if p.r == nil || p.r.Format() != formatI32 {
+		panic("pulse: PlaybackFormat24 requires an Int32Reader")
+	}
+	p.r = &pack24{r: p.r}
+	p.createRequest.Format = p.r.Format()
+	p.bytesPerSample = 3
+}
+
+// pack24 converts 32 bit samples to packed 24 bit samples.
+type pack24 struct {
+	r   Reader
+	tmp []byte
+}
+
+func (p *pack24) Read(buf []byte) (int, error) {
+	samples := len(buf) / 3
+	if cap(p.tmp) < samples*4 {
+		p.tmp = make([]byte, samples*4)
+	}
+	n, err := p.r.Read(p.tmp[:samples*4])
+	in := int32Slice(p.tmp[:n])
+	if formatI32 == proto.FormatInt32LE {
+		for i, v := range in {
+			buf[3*i], buf[3*i+1], buf[3*i+2] = byte(v), byte(v>>8), byte(v>>16)
+		}
+	} else {
+		for i, v := range in {
+			buf[3*i], buf[3*i+1], buf[3*i+2] = byte(v>>16), byte(v>>8), byte(v)
+		}
+	}
+	return 3 * len(in), err
+}
+
+func (p *pack24) Format() byte {
+	if formatI32 == proto.FormatInt32LE {
+		return proto.FormatInt24LE
+	}
+	return proto.FormatInt24BE
+}
+
+// PlaybackFormatALaw sends the audio to the server as A-law companded samples.
+// The reader passed to NewPlayback must be a Uint8Reader that provides the companded bytes.
+// If the server doesn't accept the format, NewPlayback returns an error.
+var PlaybackFormatALaw PlaybackOption = func(p *PlaybackStream) {
+	p.setCompanded(proto.FormatALaw)
+}
+
+// PlaybackFormatMuLaw sends the audio to the server as µ-law companded samples.
+// The reader passed to NewPlayback must be a Uint8Reader that provides the companded bytes.
+// If the server doesn't accept the format, NewPlayback returns an error.
+var PlaybackFormatMuLaw PlaybackOption = func(p *PlaybackStream) {
+	p.setCompanded(proto.FormatMuLaw)
+}
+
+func (p *PlaybackStream) setCompanded(format byte) {
+	if p.r == nil || p.r.Format() != proto.FormatUint8 {
+		panic("pulse: companded formats require a Uint8Reader")
+	}
+	p.r = &reader{p.r, format}
+	p.createRequest.Format = format
+	p.bytesPerSample = 1
+	p.strict = true
+}
+
+const errFormatNotSupported = pulseError("pulse: sample format not supported by the server")
+
+// PlaybackBigEndian sends the audio to the server in big-endian byte order, swapping bytes if necessary.
+// The reader still provides samples in the native byte order.
+// This can avoid a conversion on servers running on big-endian machines.
+//
+// This option should be passed after options that change the sample format.
+var PlaybackBigEndian PlaybackOption = func(p *PlaybackStream) {
+	be, ok := bigEndian[p.createRequest.Format]
+	if !ok || p.r == nil {
+		return
+	}
+	p.r = &swapper{p.r, be, bytes(be)}
+	p.createRequest.Format = be
+}
+
+// bigEndian maps little-endian formats to their big-endian counterparts.
+var bigEndian = map[byte]byte{
+	proto.FormatInt16LE:    proto.FormatInt16BE,
+	proto.FormatInt32LE:    proto.FormatInt32BE,
+	proto.FormatFloat32LE:  proto.FormatFloat32BE,
+	proto.FormatInt24LE:    proto.FormatInt24BE,
+	proto.FormatInt24_32LE: proto.FormatInt24_32BE,
+}
+
+// swapper reverses the byte order of samples.
+type swapper struct {
+	r    Reader
+	f    byte
+	size int
+}
+
+func (s *swapper) Read(buf []byte) (int, error) {
+	n, err := s.r.Read(buf)
+	for i := 0; i+s.size <= n; i += s.size {
+		for a, b := i, i+s.size-1; a < b; a, b = a+1, b-1 {
+			buf[a], buf[b] = buf[b], buf[a]
+		}
+	}
+	return n, err
+}
+
+func (s *swapper) Format() byte { return s.f }
+
 // PlaybackLatency sets the stream's latency in seconds.
 // Setting the latency too low causes underflows, resulting in audible artifacts.
 // Applications should generally use the highest acceptable latency.
@@ -263,7 +1557,7 @@ func PlaybackBufferSize(samples int) PlaybackOption {
 // Buffer size and latency should not be set at the same time.
 func PlaybackLatency(seconds float64) PlaybackOption {
 	return func(p *PlaybackStream) {
-		p.createRequest.BufferTargetLength = uint32(seconds*float64(p.createRequest.Rate)) * uint32(p.createRequest.Channels) * uint32(p.bytesPerSample)
+		p.createRequest.BufferTargetLength = uint32(LatencyToBytes(seconds, p.createRequest.SampleSpec))
 		p.createRequest.BufferMaxLength = 2 * p.createRequest.BufferTargetLength
 		p.createRequest.AdjustLatency = true
 	}
@@ -276,6 +1570,60 @@ func PlaybackSink(sink *Sink) PlaybackOption {
 	}
 }
 
+// PlaybackStartVolume sets a volume that is applied each time the stream is started, before any audio is played.
+// This avoids a short burst of audio at the wrong volume when the volume is changed after starting the stream.
+// A volume of 0 is muted, 1 is normal volume (100%), higher values amplify the audio.
+func PlaybackStartVolume(v float32) PlaybackOption {
+	return func(p *PlaybackStream) {
+		if v < 0 {
+			v = 0
+		}
+		p.startVolume = v
+	}
+}
+
+// PlaybackOnUnderflow sets a function that is called whenever the server reports an underflow of the stream.
+// f is called on the client's read goroutine, it must return quickly and must not call methods of the client
+// or its streams; start a goroutine if that is necessary.
+func PlaybackOnUnderflow(f func()) PlaybackOption {
+	return func(p *PlaybackStream) {
+		p.onUnderflow = f
+	}
+}
+
+// PlaybackOnSuspend sets a function that is called when the stream's sink is suspended or resumed by the server,
+// e.g. to save power. The function is called on a separate goroutine.
+func PlaybackOnSuspend(f func(suspended bool)) PlaybackOption {
+	return func(p *PlaybackStream) {
+		p.onSuspend = f
+	}
+}
+
+// PlaybackFallbackSinks sets sinks that will be tried in order if the stream can't be created on the preferred sink.
+// The sink that was actually used can be queried with (*PlaybackStream).Sink.
+func PlaybackFallbackSinks(sinks []*Sink) PlaybackOption {
+	return func(p *PlaybackStream) {
+		p.fallback = sinks
+	}
+}
+
+// PlaybackDontInhibitIdle marks the stream as background audio that should not keep the system from going idle.
+// The server will suspend the sink as if the stream didn't exist (the DontInhibitAutoSuspend stream flag).
+//
+// Note that PulseAudio itself only uses this for automatic suspending of sinks, which is implemented by
+// module-suspend-on-idle. Screensavers and desktop power management do not look at audio streams;
+// applications that want to inhibit them must do so through the desktop's own interface (e.g. org.freedesktop.ScreenSaver).
+var PlaybackDontInhibitIdle PlaybackOption = func(p *PlaybackStream) {
+	p.createRequest.DontInhibitAutoSuspend = true
+}
+
+// PlaybackInhibitIdle marks the stream as audio that should keep the sink from suspending while the stream exists,
+// e.g. for calls. This is the default, the option can be used to override an earlier PlaybackDontInhibitIdle.
+// See PlaybackDontInhibitIdle for which components honor this.
+var PlaybackInhibitIdle PlaybackOption = func(p *PlaybackStream) {
+	p.createRequest.DontInhibitAutoSuspend = false
+}
+
 // PlaybackMediaName sets the streams media name.
 // This will e.g. be displayed by a volume control application to identity the stream.
 func PlaybackMediaName(name string) PlaybackOption {
@@ -292,6 +1640,47 @@ func PlaybackMediaIconName(name string) PlaybackOption {
 	}
 }
 
+// PlaybackRole sets the streams media role, which the server uses for policies like ducking or corking
+// other streams while a phone call is active. It panics if role is not one of
+// "video", "music", "game", "event", "phone", "animation", "production", "a11y" or "test".
+func PlaybackRole(role string) PlaybackOption {
+	if !mediaRoles[role] {
+		panic("pulse: unknown media role " + role)
+	}
+	return func(p *PlaybackStream) {
+		p.createRequest.Properties["media.role"] = proto.PropListString(role)
+	}
+}
+
+// mediaRoles contains the media roles defined by the server's property list documentation.
+var mediaRoles = map[string]bool{
+	"video":      true,
+	"music":      true,
+	"game":       true,
+	"event":      true,
+	"phone":      true,
+	"animation":  true,
+	"production": true,
+	"a11y":       true,
+	"test":       true,
+}
+
+// PlaybackApplicationProcessID overrides the process id the stream is attributed to.
+// By default, the stream inherits the process id of the client, which is the current process.
+func PlaybackApplicationProcessID(pid int) PlaybackOption {
+	return func(p *PlaybackStream) {
+		p.createRequest.Properties["application.process.id"] = proto.PropListString(strconv.Itoa(pid))
+	}
+}
+
+// PlaybackApplicationBinary overrides the name of the binary the stream is attributed to.
+// By default, the stream inherits the binary name of the client, which is the current process.
+func PlaybackApplicationBinary(name string) PlaybackOption {
+	return func(p *PlaybackStream) {
+		p.createRequest.Properties["application.process.binary"] = proto.PropListString(name)
+	}
+}
+
 // PlaybackRawOption can be used to create custom options.
 //
 // This is an advanced function, similar to (*Client).RawRequest.
@@ -0,0 +1,273 @@
+package pulse
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jfreymuth/pulse/proto"
+)
+
+// benchConn discards everything written to it, reads block until it is closed.
+type benchConn struct {
+	*io.PipeReader
+}
+
+func (benchConn) Write(b []byte) (int, error) { return len(b), nil }
+
+const requestSize = 4096
+
+// newTestPlayback returns a running stream that sends its data to a connection that discards it.
+// The returned function closes the connection.
+func newTestPlayback(r Reader) (*PlaybackStream, func()) {
+	pr, pw := io.Pipe()
+	pc := &proto.Client{}
+	pc.Open(benchConn{pr})
+	p := &PlaybackStream{
+		c:       &Client{pc: pc},
+		r:       r,
+		state:   running,
+		request: make(chan int),
+		started: make(chan bool, 1),
+		createReply: proto.CreatePlaybackStreamReply{
+			SampleSpec: proto.SampleSpec{Format: r.Format(), Channels: 1},
+		},
+		bytesPerSample: bytes(r.Format()),
+		front:          make([]byte, requestSize),
+		back:           make([]byte, requestSize),
+	}
+	return p, func() { pw.Close() }
+}
+
+func TestStopWhilePlaying(t *testing.T) {
+	p, closeConn := newTestPlayback(Uint8Reader(func(buf []byte) (int, error) { return len(buf), nil }))
+	defer closeConn()
+	done := make(chan struct{})
+	go func() {
+		p.run(p.request, make(chan struct{}))
+		close(done)
+	}()
+	go func() {
+		for i := 0; i < 100; i++ {
+			p.sendRequest(requestSize)
+		}
+		p.shutdown(closed)
+	}()
+	for !p.Closed() {
+		p.Stop()
+		p.Running()
+		p.Underflow()
+		p.Error()
+	}
+	<-done
+}
+
+// testServer is a connection that answers the requests of a client like a server with a single sink would.
+// Every packet of audio data is answered with a request for the same amount of data.
+// Drain requests for a corked stream are never answered.
+type testServer struct {
+	*io.PipeReader
+	in     []byte
+	out    chan []byte
+	stream uint32
+	corked bool
+}
+
+func newTestServer() (*testServer, func()) {
+	pr, pw := io.Pipe()
+	s := &testServer{PipeReader: pr, out: make(chan []byte, 64)}
+	go func() {
+		for b := range s.out {
+			pw.Write(b)
+		}
+	}()
+	return s, func() { pw.Close() }
+}
+
+func (s *testServer) Write(b []byte) (int, error) {
+	s.in = append(s.in, b...)
+	for len(s.in) >= 20 {
+		length := int(binary.BigEndian.Uint32(s.in))
+		if len(s.in) < 20+length {
+			break
+		}
+		index := binary.BigEndian.Uint32(s.in[4:])
+		data := s.in[20 : 20+length]
+		if index != 0xFFFFFFFF {
+			s.send(proto.OpRequest, 0xFFFFFFFF, 'L', index, 'L', uint32(length))
+		} else {
+			op, tag := binary.BigEndian.Uint32(data[1:]), binary.BigEndian.Uint32(data[6:])
+			switch op {
+			case proto.OpCreatePlaybackStream:
+				s.stream++
+				s.send(proto.OpReply, tag,
+					'L', s.stream, 'L', s.stream, 'L', uint32(0),
+					'L', uint32(requestSize), 'L', uint32(requestSize), 'L', uint32(0), 'L', uint32(requestSize),
+					'a', byte(proto.FormatUint8), byte(1), uint32(44100), 'm', byte(1), byte(proto.ChannelMono),
+					'L', uint32(0), 'N', '0', 'U', uint64(0), 'f', 'B', byte(1), 'P', 'N')
+			case proto.OpCorkPlaybackStream:
+				s.corked = data[15] == '1'
+				s.send(proto.OpReply, tag)
+				if !s.corked {
+					s.send(proto.OpStarted, 0xFFFFFFFF, 'L', s.stream)
+				}
+			case proto.OpDrainPlaybackStream:
+				if !s.corked {
+					s.send(proto.OpReply, tag)
+				}
+			default:
+				s.send(proto.OpReply, tag)
+			}
+		}
+		s.in = s.in[20+length:]
+	}
+	return len(b), nil
+}
+
+// send sends a control packet, the values are encoded as bytes and big-endian integers.
+func (s *testServer) send(op, tag uint32, values ...interface{}) {
+	b := make([]byte, 20, 64)
+	b = append(b, 'L', 0, 0, 0, 0, 'L', 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[21:], op)
+	binary.BigEndian.PutUint32(b[26:], tag)
+	for _, v := range values {
+		switch v := v.(type) {
+		case byte:
+			b = append(b, v)
+		case rune:
+			b = append(b, byte(v))
+		case uint32:
+			b = append(b, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(b[len(b)-4:], v)
+		case uint64:
+			b = append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+			binary.BigEndian.PutUint64(b[len(b)-8:], v)
+		}
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)-20))
+	binary.BigEndian.PutUint32(b[4:], 0xFFFFFFFF)
+	s.out <- b
+}
+
+// newTestClient returns a client connected to a testServer.
+// The returned function closes the connection.
+func newTestClient() (*Client, func()) {
+	srv, closeConn := newTestServer()
+	pc := &proto.Client{}
+	pc.SetTimeout(time.Second)
+	c := &Client{pc: pc, playback: make(map[uint32]*PlaybackStream), record: make(map[uint32]*RecordStream)}
+	pc.Callback = func(msg interface{}) {
+		if _, ok := msg.(*proto.ConnectionClosed); !ok {
+			c.dispatch(pc, msg)
+		}
+	}
+	pc.Open(srv)
+	return c, closeConn
+}
+
+func TestRecreateWhilePlaying(t *testing.T) {
+	c, closeConn := newTestClient()
+	defer closeConn()
+	p := c.newPlayback(Uint8Reader(func(buf []byte) (int, error) { return len(buf), nil }), nil)
+	if err := p.create(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := p.Recreate(); err != nil {
+			t.Fatal(err)
+		}
+		if !p.Running() {
+			t.Fatal("stream was not restarted")
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnDrainedAfterStop(t *testing.T) {
+	c, closeConn := newTestClient()
+	defer closeConn()
+	p := c.newPlayback(Uint8Reader(func(buf []byte) (int, error) { return len(buf), nil }), nil)
+	if err := p.create(); err != nil {
+		t.Fatal(err)
+	}
+	drained := make(chan struct{})
+	p.OnDrained(func() { close(drained) })
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+	select {
+	case <-drained:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnDrained was not called after Stop")
+	}
+	p.Close()
+}
+
+func TestPlaybackWriterClosePaused(t *testing.T) {
+	c, closeConn := newTestClient()
+	defer closeConn()
+	w, err := c.NewPlaybackWriter(proto.FormatUint8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	w.Pause()
+	done := make(chan error, 1)
+	go func() { done <- w.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on a paused stream")
+	}
+}
+
+func benchmarkPlayback(b *testing.B, r Reader) {
+	p, closeConn := newTestPlayback(r)
+	defer closeConn()
+	done := make(chan struct{})
+	go func() {
+		p.run(p.request, make(chan struct{}))
+		close(done)
+	}()
+
+	b.ReportAllocs()
+	b.SetBytes(requestSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.sendRequest(requestSize)
+	}
+	close(p.request)
+	<-done
+}
+
+func BenchmarkPlaybackUint8(b *testing.B) {
+	benchmarkPlayback(b, Uint8Reader(func(buf []byte) (int, error) { return len(buf), nil }))
+}
+
+func BenchmarkPlaybackInt16(b *testing.B) {
+	benchmarkPlayback(b, Int16Reader(func(buf []int16) (int, error) { return len(buf), nil }))
+}
+
+func BenchmarkPlaybackInt32(b *testing.B) {
+	benchmarkPlayback(b, Int32Reader(func(buf []int32) (int, error) { return len(buf), nil }))
+}
+
+func BenchmarkPlaybackFloat32(b *testing.B) {
+	benchmarkPlayback(b, Float32Reader(func(buf []float32) (int, error) { return len(buf), nil }))
+}
+
+func BenchmarkPlaybackFloat64(b *testing.B) {
+	benchmarkPlayback(b, Float64Reader(func(buf []float64) (int, error) { return len(buf), nil }))
+}
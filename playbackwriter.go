@@ -0,0 +1,197 @@
+package pulse
+
+import (
+	"context"
+	"sync"
+)
+
+// A PlaybackWriter is a playback stream that is fed by calls to Write instead of a callback.
+// This allows e.g. copying audio from a decoder using io.Copy.
+//
+// Written data is stored in a buffer of the stream's target size. Write blocks while that buffer is full,
+// so the rate of writes is determined by the server. If the buffer runs empty, the stream will underflow,
+// which can be detected with Underflow.
+type PlaybackWriter struct {
+	*PlaybackStream
+
+	mu     sync.Mutex
+	buf    []byte
+	start  int
+	n      int
+	frame  int
+	closed bool
+	space  chan struct{}
+	quit   chan struct{} // closed by Close
+}
+
+// NewPlaybackWriter creates a playback stream that plays audio written to it.
+// The data must be in the given format, see the constants defined in the proto package.
+// The stream is started immediately, playback begins as soon as enough data has been written.
+func (c *Client) NewPlaybackWriter(format byte, opts ...PlaybackOption) (*PlaybackWriter, error) {
+	check(format)
+	w := &PlaybackWriter{space: make(chan struct{}, 1), quit: make(chan struct{})}
+	p, err := c.NewPlayback(playbackWriterReader{w, format}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	w.PlaybackStream = p
+	r := p.reply()
+	w.frame = int(r.Channels) * p.bytesPerSample
+	size := int(r.BufferTargetLength)
+	if size < w.frame {
+		size = w.frame
+	}
+	w.buf = make([]byte, size)
+	p.wake = make(chan struct{}, 1)
+	if err := p.start(false); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write writes audio data to the stream, blocking while the buffer is full.
+// Partial frames are kept until the rest of the frame is written.
+func (w *PlaybackWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	written := 0
+	for len(b) > 0 {
+		if w.closed || w.PlaybackStream.Closed() {
+			return written, errWriterClosed
+		}
+		if w.n == len(w.buf) {
+			w.mu.Unlock()
+			select {
+			case <-w.space:
+			case <-w.quit:
+			case <-w.PlaybackStream.Done():
+			}
+			w.mu.Lock()
+			continue
+		}
+		end := (w.start + w.n) % len(w.buf)
+		limit := len(w.buf)
+		if end < w.start {
+			limit = w.start
+		}
+		if free := len(w.buf) - w.n; limit-end > free {
+			limit = end + free
+		}
+		n := copy(w.buf[end:limit], b)
+		w.n += n
+		written += n
+		b = b[n:]
+		w.signal()
+	}
+	return written, nil
+}
+
+// signal notifies the stream that data is available.
+func (w *PlaybackWriter) signal() {
+	select {
+	case w.PlaybackStream.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *PlaybackWriter) read(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	avail := w.n - w.n%w.frame
+	if avail == 0 && w.closed {
+		return 0, EndOfData
+	}
+	b = b[:len(b)-len(b)%w.frame]
+	n := 0
+	for n < len(b) && avail > 0 {
+		limit := w.start + avail
+		if limit > len(w.buf) {
+			limit = len(w.buf)
+		}
+		m := copy(b[n:], w.buf[w.start:limit])
+		n += m
+		avail -= m
+		w.n -= m
+		w.start = (w.start + m) % len(w.buf)
+	}
+	if n > 0 {
+		select {
+		case w.space <- struct{}{}:
+		default:
+		}
+	}
+	return n, nil
+}
+
+// Flush discards all audio that was written but not played yet, see (*PlaybackStream).Flush.
+func (w *PlaybackWriter) Flush() error {
+	w.mu.Lock()
+	w.start, w.n = 0, 0
+	w.mu.Unlock()
+	select {
+	case w.space <- struct{}{}:
+	default:
+	}
+	return w.PlaybackStream.Flush()
+}
+
+// Pause pauses the stream, see (*PlaybackStream).Pause.
+// A call to Close that is waiting for the buffered audio to be played returns without playing the rest.
+func (w *PlaybackWriter) Pause() {
+	w.PlaybackStream.Pause()
+	w.wakeClose()
+}
+
+// Stop stops the stream, see (*PlaybackStream).Stop.
+// A call to Close that is waiting for the buffered audio to be sent returns without sending the rest.
+func (w *PlaybackWriter) Stop() {
+	w.PlaybackStream.Stop()
+	w.wakeClose()
+}
+
+// wakeClose makes a waiting call to Close check the state of the stream again.
+func (w *PlaybackWriter) wakeClose() {
+	select {
+	case w.space <- struct{}{}:
+	default:
+	}
+}
+
+// Close plays the remaining buffered audio, waits until it has been played, and closes the stream.
+// If the stream is paused, the buffered audio is discarded. Incomplete frames are always discarded.
+// Calling Close again does nothing and returns nil.
+func (w *PlaybackWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.quit)
+	w.signal()
+	for w.n >= w.frame && w.PlaybackStream.Running() {
+		w.mu.Unlock()
+		select {
+		case <-w.space:
+		case <-w.PlaybackStream.Done():
+		}
+		w.mu.Lock()
+	}
+	w.mu.Unlock()
+	if !w.PlaybackStream.Closed() {
+		// a paused stream would never finish draining, drainContext returns ErrPaused instead
+		w.PlaybackStream.drainContext(context.Background())
+	}
+	return w.PlaybackStream.Close()
+}
+
+const errWriterClosed = pulseError("pulse: write to closed stream")
+
+type playbackWriterReader struct {
+	w *PlaybackWriter
+	f byte
+}
+
+func (r playbackWriterReader) Read(b []byte) (int, error) { return r.w.read(b) }
+func (r playbackWriterReader) Format() byte               { return r.f }
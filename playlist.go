@@ -0,0 +1,149 @@
+package pulse
+
+import (
+	"sync"
+	"time"
+)
+
+// A Playlist plays a sequence of tracks through a single playback stream.
+// There are no gaps between tracks: as soon as a track ends, the next one is read to fill the same buffer.
+// When the playlist runs out of tracks, it plays silence until more are enqueued.
+type Playlist struct {
+	c    *Client
+	opts []PlaybackOption
+
+	mu            sync.Mutex
+	stream        *PlaybackStream
+	format        byte
+	queue         []Reader
+	played        int
+	pos           int
+	onTrackChange func(track int)
+}
+
+// NewPlaylist creates an empty playlist.
+// The playback stream is created with the given options when the first track is enqueued.
+func (c *Client) NewPlaylist(opts ...PlaybackOption) *Playlist {
+	return &Playlist{c: c, opts: opts}
+}
+
+// Enqueue appends a track to the playlist and starts playback if necessary.
+// A track ends when its reader returns an error, usually EndOfData. An error other than EndOfData
+// does not stop the playlist, the track is skipped instead.
+// All tracks must have the same format as the first one, and should only return whole frames from Read.
+func (l *Playlist) Enqueue(r Reader) error {
+	l.mu.Lock()
+	if l.stream == nil {
+		l.format = r.Format()
+		stream, err := l.c.NewPlayback(playlistReader{l}, l.opts...)
+		if err != nil {
+			l.mu.Unlock()
+			return err
+		}
+		l.stream = stream
+	} else if r.Format() != l.format {
+		l.mu.Unlock()
+		return errPlaylistFormat
+	}
+	l.queue = append(l.queue, r)
+	stream := l.stream
+	l.mu.Unlock()
+	// a paused playlist stays paused
+	if err := stream.Start(); err != nil && err != ErrPaused {
+		return err
+	}
+	return stream.Error()
+}
+
+const errPlaylistFormat = pulseError("pulse: track format does not match the playlist")
+
+// Track returns the index of the track that is currently being read, counting all tracks ever enqueued from 0.
+// If the playlist is empty, Track returns -1.
+//
+// Since audio is buffered, the track that is heard may lag behind by the stream's latency.
+func (l *Playlist) Track() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.queue) == 0 {
+		return -1
+	}
+	return l.played
+}
+
+// Position returns how much of the current track has been read.
+func (l *Playlist) Position() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stream == nil {
+		return 0
+	}
+	return time.Duration(BytesToLatency(l.pos, l.stream.SampleSpec()) * float64(time.Second))
+}
+
+// OnTrackChange sets a function that is called whenever a track ends, with the index of the following track,
+// or -1 if there is none. The function is called from the stream's goroutine and should return quickly.
+func (l *Playlist) OnTrackChange(f func(track int)) {
+	l.mu.Lock()
+	l.onTrackChange = f
+	l.mu.Unlock()
+}
+
+// Stream returns the playlist's playback stream, or nil if no track was enqueued yet.
+func (l *Playlist) Stream() *PlaybackStream {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stream
+}
+
+// Close closes the playlist's stream and discards all remaining tracks.
+func (l *Playlist) Close() error {
+	l.mu.Lock()
+	stream := l.stream
+	l.queue = nil
+	l.mu.Unlock()
+	if stream != nil {
+		return stream.Close()
+	}
+	return nil
+}
+
+func (l *Playlist) read(buf []byte) (int, error) {
+	l.mu.Lock()
+	n := 0
+	changed := 0
+	for n < len(buf) && len(l.queue) > 0 {
+		m, err := l.queue[0].Read(buf[n:])
+		n += m
+		l.pos += m
+		if err != nil {
+			l.queue[0] = nil
+			l.queue = l.queue[1:]
+			l.played++
+			l.pos = 0
+			changed++
+		} else if m == 0 {
+			break
+		}
+	}
+	s := silence(l.format)
+	for i := n; i < len(buf); i++ {
+		buf[i] = s
+	}
+	f, played, empty := l.onTrackChange, l.played, len(l.queue) == 0
+	l.mu.Unlock()
+	if f != nil {
+		for i := played - changed + 1; i <= played; i++ {
+			if i == played && empty {
+				f(-1)
+			} else {
+				f(i)
+			}
+		}
+	}
+	return len(buf), nil
+}
+
+type playlistReader struct{ l *Playlist }
+
+func (r playlistReader) Read(buf []byte) (int, error) { return r.l.read(buf) }
+func (r playlistReader) Format() byte                 { return r.l.format }
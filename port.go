@@ -0,0 +1,70 @@
+package pulse
+
+import "github.com/jfreymuth/pulse/proto"
+
+// A Port is a physical connector of a device, e.g. speakers or headphones.
+type Port struct {
+	Name        string
+	Description string
+	Priority    int
+	Available   PortAvailability
+}
+
+// PortAvailability indicates whether something is plugged into a port.
+type PortAvailability uint32
+
+// Possible values for PortAvailability.
+// Many devices are unable to detect whether something is plugged in and always report PortAvailabilityUnknown.
+const (
+	PortAvailabilityUnknown PortAvailability = 0
+	PortUnavailable         PortAvailability = 1
+	PortAvailable           PortAvailability = 2
+)
+
+// Ports returns the sink's ports.
+func (s *Sink) Ports() []Port {
+	ports := make([]Port, len(s.info.Ports))
+	for i, p := range s.info.Ports {
+		ports[i] = Port{p.Name, p.Description, int(p.Priority), PortAvailability(p.Available)}
+	}
+	return ports
+}
+
+// Ports returns the source's ports.
+func (s *Source) Ports() []Port {
+	ports := make([]Port, len(s.info.Ports))
+	for i, p := range s.info.Ports {
+		ports[i] = Port{p.Name, p.Description, int(p.Priority), PortAvailability(p.Available)}
+	}
+	return ports
+}
+
+// ActivePort returns the name of the sink's active port, or an empty string if the sink has no ports.
+func (s *Sink) ActivePort() string {
+	return s.info.ActivePortName
+}
+
+// SetPort makes the port with the given name the sink's active port.
+func (s *Sink) SetPort(name string) error {
+	err := s.c.c().Request(&proto.SetSinkPort{SinkIndex: s.info.SinkIndex, Port: name}, nil)
+	if err != nil {
+		return err
+	}
+	s.info.ActivePortName = name
+	return nil
+}
+
+// ActivePort returns the name of the source's active port, or an empty string if the source has no ports.
+func (s *Source) ActivePort() string {
+	return s.info.ActivePortName
+}
+
+// SetPort makes the port with the given name the source's active port.
+func (s *Source) SetPort(name string) error {
+	err := s.c.c().Request(&proto.SetSourcePort{SourceIndex: s.info.SourceIndex, Port: name}, nil)
+	if err != nil {
+		return err
+	}
+	s.info.ActivePortName = name
+	return nil
+}
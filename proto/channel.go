@@ -0,0 +1,100 @@
+package proto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChannelPositionName returns a human-readable name for a channel position, e.g. "Front Left".
+func ChannelPositionName(pos byte) string {
+	switch {
+	case pos < ChannelAux0:
+		return channelNames[pos].pretty
+	case pos <= ChannelAux31:
+		return "Auxiliary " + strconv.Itoa(int(pos-ChannelAux0))
+	case pos <= ChannelTopRearCenter:
+		return channelNames[pos-ChannelTopCenter+ChannelAux0].pretty
+	}
+	return "Unknown"
+}
+
+// Names returns a human-readable name for each channel, see ChannelPositionName.
+func (m ChannelMap) Names() []string {
+	names := make([]string, len(m))
+	for i, pos := range m {
+		names[i] = ChannelPositionName(pos)
+	}
+	return names
+}
+
+// ChannelPositionFromName returns the channel position with the given name.
+// It accepts both the names returned by ChannelPositionName and the names used in PulseAudio's
+// configuration files and command line tools, e.g. "front-left" or "aux3". Case is ignored.
+func ChannelPositionFromName(name string) (byte, error) {
+	n := strings.ToLower(strings.TrimSpace(name))
+	switch n {
+	case "left":
+		return ChannelLeft, nil
+	case "right":
+		return ChannelRight, nil
+	case "center":
+		return ChannelCenter, nil
+	case "subwoofer":
+		return ChannelLFE, nil
+	}
+	for i, c := range channelNames {
+		if n == c.short || n == strings.ToLower(c.pretty) {
+			if i >= ChannelAux0 {
+				return byte(i - ChannelAux0 + ChannelTopCenter), nil
+			}
+			return byte(i), nil
+		}
+	}
+	for _, prefix := range []string{"aux", "auxiliary "} {
+		if strings.HasPrefix(n, prefix) {
+			if i, err := strconv.Atoi(n[len(prefix):]); err == nil && i >= 0 && i <= ChannelAux31-ChannelAux0 {
+				return byte(ChannelAux0 + i), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("pulseaudio: unknown channel position %q", name)
+}
+
+// ParseChannelMap parses a comma-separated list of channel position names, see ChannelPositionFromName.
+func ParseChannelMap(s string) (ChannelMap, error) {
+	parts := strings.Split(s, ",")
+	m := make(ChannelMap, len(parts))
+	for i, p := range parts {
+		pos, err := ChannelPositionFromName(p)
+		if err != nil {
+			return nil, err
+		}
+		m[i] = pos
+	}
+	return m, nil
+}
+
+// channelNames contains the names of all positions except the auxiliary channels,
+// positions after ChannelAux31 are stored starting at index ChannelAux0.
+var channelNames = [...]struct{ short, pretty string }{
+	{"mono", "Mono"},
+	{"front-left", "Front Left"},
+	{"front-right", "Front Right"},
+	{"front-center", "Front Center"},
+	{"rear-center", "Rear Center"},
+	{"rear-left", "Rear Left"},
+	{"rear-right", "Rear Right"},
+	{"lfe", "LFE"},
+	{"front-left-of-center", "Front Left-of-center"},
+	{"front-right-of-center", "Front Right-of-center"},
+	{"side-left", "Side Left"},
+	{"side-right", "Side Right"},
+	{"top-center", "Top Center"},
+	{"top-front-left", "Top Front Left"},
+	{"top-front-right", "Top Front Right"},
+	{"top-front-center", "Top Front Center"},
+	{"top-rear-left", "Top Rear Left"},
+	{"top-rear-right", "Top Rear Right"},
+	{"top-rear-center", "Top Rear Center"},
+}
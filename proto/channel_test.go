@@ -0,0 +1,40 @@
+package proto
+
+import "testing"
+
+func TestChannelPositionNames(t *testing.T) {
+	for pos := 0; pos <= ChannelTopRearCenter; pos++ {
+		name := ChannelPositionName(byte(pos))
+		if name == "Unknown" {
+			t.Errorf("no name for position %d", pos)
+			continue
+		}
+		p, err := ChannelPositionFromName(name)
+		if err != nil {
+			t.Errorf("ChannelPositionFromName(%q): %v", name, err)
+		} else if p != byte(pos) {
+			t.Errorf("ChannelPositionFromName(%q) = %d, expected %d", name, p, pos)
+		}
+	}
+	if name := ChannelPositionName(ChannelTopRearCenter + 1); name != "Unknown" {
+		t.Errorf("ChannelPositionName(%d) = %q, expected \"Unknown\"", ChannelTopRearCenter+1, name)
+	}
+}
+
+func TestParseChannelMap(t *testing.T) {
+	m, err := ParseChannelMap("front-left,front-right, rear-left,rear-right,lfe,aux7,top-rear-center")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ChannelMap{ChannelFrontLeft, ChannelFrontRight, ChannelRearLeft, ChannelRearRight,
+		ChannelLFE, ChannelAux0 + 7, ChannelTopRearCenter}
+	if string(m) != string(expected) {
+		t.Errorf("ParseChannelMap returned %v, expected %v", m, expected)
+	}
+	if _, err := ParseChannelMap("front-left,nowhere"); err == nil {
+		t.Error("ParseChannelMap accepted an unknown position")
+	}
+	if _, err := ParseChannelMap("aux32"); err == nil {
+		t.Error("ParseChannelMap accepted aux32")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"errors"
-	"fmt"
 	"io"
 	"reflect"
 	"sync"
@@ -47,7 +46,7 @@ func (c *Client) Open(rw io.ReadWriter) {
 	//debug, _ := os.Create("debug")
 	//c.r.r = io.TeeReader(rw, debug)
 	c.r.r = rw
-	c.w.w = rw
+	c.w.w = retryWriter{rw}
 	c.v = Version(32)
 
 	c.awaitReply = make(map[uint32]AwaitReply)
@@ -60,13 +59,18 @@ type AwaitReply struct {
 }
 
 func (c *Client) Request(req RequestArgs, rpl Reply) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.RequestContext(ctx, req, rpl)
+}
+
+// RequestContext is like Request, but waits for the reply until the context is done instead of using the client's timeout.
+// If the context is done before the reply arrives, the reply will be discarded and rpl will not be modified.
+func (c *Client) RequestContext(ctx context.Context, req RequestArgs, rpl Reply) error {
 	if rpl != nil && req.command() != rpl.IsReplyTo() {
 		panic("pulse: wrong reply type")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
-	defer cancel()
-
 	reply := make(chan error, 1)
 	c.replyM.Lock()
 	if c.err != nil {
@@ -96,9 +100,16 @@ func (c *Client) Request(req RequestArgs, rpl Reply) error {
 	case err := <-reply:
 		return err
 	case <-ctx.Done():
+		c.replyM.Lock()
+		_, pending := c.awaitReply[tag]
+		delete(c.awaitReply, tag)
+		c.replyM.Unlock()
+		if !pending {
+			// the read loop has stored the reply and sends it right away, see readLoop
+			return <-reply
+		}
 		return ctx.Err()
 	}
-
 }
 
 func (c *Client) Send(index uint32, data []byte) error {
@@ -112,9 +123,24 @@ func (c *Client) Send(index uint32, data []byte) error {
 	c.w.uint64(0)
 	c.w.uint32(0)
 	c.w.flush()
-	c.w.w.Write(data)
+	if c.w.err == nil {
+		_, c.w.err = c.w.w.Write(data)
+	}
+	err := c.w.err
 	c.writeM.Unlock()
-	return nil
+	return err
+}
+
+// Flush writes any buffered data to the connection.
+// Send already writes each packet before returning, so this only reports a previous write error.
+func (c *Client) Flush() error {
+	c.writeM.Lock()
+	defer c.writeM.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.w.flush()
+	return c.w.err
 }
 
 func (c *Client) readLoop() {
@@ -128,15 +154,33 @@ func (c *Client) readLoop() {
 			c.error(c.r.err)
 			return
 		}
+		if length > maxFrameSize {
+			c.error(ErrProtocol)
+			return
+		}
 		if index == 0xFFFFFFFF {
-			c.r.byte() // L
+			if length < 10 {
+				c.error(ErrProtocol)
+				return
+			}
+			start := c.r.pos
+			if c.r.byte() != 'L' {
+				c.error(ErrProtocol)
+				return
+			}
 			op := c.r.uint32()
-			c.r.byte() // L
+			if c.r.byte() != 'L' {
+				c.error(ErrProtocol)
+				return
+			}
 			tag := c.r.uint32()
 			var message interface{}
 			switch op {
 			case OpError:
-				c.r.byte()
+				if c.r.byte() != 'L' {
+					c.error(ErrProtocol)
+					return
+				}
 				err := Error(c.r.uint32())
 				c.replyM.Lock()
 				a, ok := c.awaitReply[tag]
@@ -148,22 +192,34 @@ func (c *Client) readLoop() {
 			case OpReply:
 				c.replyM.Lock()
 				a, ok := c.awaitReply[tag]
-				delete(c.awaitReply, tag)
 				c.replyM.Unlock()
-				if ok {
-					if a.value != nil {
-						if reflect.TypeOf(a.value).Elem().Kind() == reflect.Slice {
-							c.parseInfoList(a.value, int(length)-10)
-						} else {
-							c.r.value(a.value, c.v)
-						}
+				// the reply is parsed into a new value, so a request that stops waiting while the reply is
+				// being read doesn't see its reply change after it returned
+				var v reflect.Value
+				if ok && a.value != nil {
+					v = reflect.New(reflect.TypeOf(a.value).Elem())
+					if v.Elem().Kind() == reflect.Slice {
+						c.parseInfoList(v.Interface(), int(length)-10)
 					} else {
-						c.r.advance(int(length) - 10)
+						c.r.value(v.Interface(), c.v)
 					}
-					a.reply <- nil
 				} else {
 					c.r.advance(int(length) - 10)
 				}
+				if err := c.frameError(start, length); err != nil {
+					c.error(err)
+					return
+				}
+				c.replyM.Lock()
+				a, ok = c.awaitReply[tag]
+				delete(c.awaitReply, tag)
+				if ok && v.IsValid() {
+					reflect.ValueOf(a.value).Elem().Set(v.Elem())
+				}
+				c.replyM.Unlock()
+				if ok {
+					a.reply <- nil
+				}
 			case OpRequest:
 				message = &Request{}
 			case OpOverflow:
@@ -195,14 +251,17 @@ func (c *Client) readLoop() {
 			case OpPlaybackBufferAttrChanged:
 				message = &PlaybackBufferAttrChanged{}
 			default:
-				fmt.Println(op)
 				c.r.advance(int(length) - 10)
 			}
 			if message != nil {
 				c.r.value(message, c.v)
-				if c.Callback != nil {
-					c.Callback(message)
-				}
+			}
+			if err := c.frameError(start, length); err != nil {
+				c.error(err)
+				return
+			}
+			if message != nil && c.Callback != nil {
+				c.Callback(message)
 			}
 		} else {
 			if c.Callback != nil {
@@ -214,6 +273,20 @@ func (c *Client) readLoop() {
 	}
 }
 
+// frameError checks that a packet starting at start was parsed without consuming more than length bytes,
+// and skips any data that wasn't parsed.
+func (c *Client) frameError(start int, length uint32) error {
+	if c.r.err != nil {
+		return c.r.err
+	}
+	n := c.r.pos - start
+	if n > int(length) {
+		return ErrProtocol
+	}
+	c.r.advance(int(length) - n)
+	return c.r.err
+}
+
 func (c *Client) error(err error) {
 	c.replyM.Lock()
 	c.writeM.Lock()
@@ -225,14 +298,15 @@ func (c *Client) error(err error) {
 	for _, r := range r {
 		r.reply <- err
 	}
-	if errors.Is(err, io.EOF) {
-		c.Callback(&ConnectionClosed{})
+	if c.Callback != nil {
+		c.Callback(&ConnectionClosed{Err: err})
 	}
 }
 
 func (c *Client) parseInfoList(value interface{}, length int) {
 	start := c.r.pos
-	for c.r.pos-start < length {
+	// a malformed value doesn't advance the reader, so stop at the first error
+	for c.r.err == nil && c.r.pos-start < length {
 		switch value := value.(type) {
 		case *GetSinkInfoListReply:
 			var v GetSinkInfoReply
@@ -277,4 +351,15 @@ type DataPacket struct {
 	Data        []byte
 }
 
-type ConnectionClosed struct{}
+// ConnectionClosed is passed to the callback when the connection can no longer be used.
+// Err is io.EOF if the server closed the connection, or ErrProtocol if the client received data it couldn't parse.
+type ConnectionClosed struct {
+	Err error
+}
+
+// ErrProtocol is returned when the client receives data it can't parse, e.g. because of an incompatible server.
+// The connection can't be used after this error.
+var ErrProtocol = errors.New("pulseaudio: invalid data received")
+
+// maxFrameSize is the largest packet the client accepts, anything larger is treated as a protocol error.
+const maxFrameSize = 16 * 1024 * 1024
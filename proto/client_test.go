@@ -0,0 +1,146 @@
+package proto
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type testConn struct {
+	io.Reader
+	io.Writer
+}
+
+func TestMalformedPacket(t *testing.T) {
+	var buf bytes.Buffer
+	w := ProtocolWriter{w: &buf}
+	// control packet with an invalid tag byte instead of 'L'
+	w.uint32(10)
+	w.uint32(0xFFFFFFFF)
+	w.uint64(0)
+	w.uint32(0)
+	w.byte('x')
+	w.uint32(OpReply)
+	w.byte('L')
+	w.uint32(0)
+	w.byte(0)
+	w.flush()
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	var c Client
+	c.SetTimeout(time.Second)
+	closed := make(chan error, 1)
+	c.Callback = func(msg interface{}) {
+		if msg, ok := msg.(*ConnectionClosed); ok {
+			closed <- msg.Err
+		}
+	}
+	c.Open(testConn{io.MultiReader(&buf, pr), ioutil.Discard})
+
+	select {
+	case err := <-closed:
+		if err != ErrProtocol {
+			t.Errorf("expected ErrProtocol, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("malformed packet was not detected")
+	}
+	if err := c.Request(&GetServerInfo{}, &GetServerInfoReply{}); err != ErrProtocol {
+		t.Errorf("expected ErrProtocol from request, got %v", err)
+	}
+
+	// reply to a list request with an invalid tag byte inside the list
+	pr, pw = io.Pipe()
+	defer pw.Close()
+	sent := make(chan struct{})
+	var c2 Client
+	c2.SetTimeout(time.Second)
+	c2.Open(testConn{pr, writerFunc(func(b []byte) (int, error) {
+		select {
+		case <-sent:
+		default:
+			close(sent)
+		}
+		return len(b), nil
+	})})
+	reply := make(chan error, 1)
+	go func() { reply <- c2.Request(&GetSinkInfoList{}, &GetSinkInfoListReply{}) }()
+	<-sent
+	buf.Reset()
+	w.uint32(14)
+	w.uint32(0xFFFFFFFF)
+	w.uint64(0)
+	w.uint32(0)
+	w.byte('L')
+	w.uint32(OpReply)
+	w.byte('L')
+	w.uint32(0)
+	w.byte('?')
+	w.byte(0)
+	w.byte(0)
+	w.byte(0)
+	w.flush()
+	go pw.Write(buf.Bytes())
+
+	select {
+	case err := <-reply:
+		if err != ErrProtocol {
+			t.Errorf("expected ErrProtocol from list request, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("malformed list reply was not detected")
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) { return f(b) }
+
+func TestRequestContextStalledReply(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	sent := make(chan struct{})
+	var c Client
+	c.Open(testConn{pr, writerFunc(func(b []byte) (int, error) {
+		select {
+		case <-sent:
+		default:
+			close(sent)
+		}
+		return len(b), nil
+	})})
+	reply := make(chan error, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var info GetServerInfoReply
+	go func() { reply <- c.RequestContext(ctx, &GetServerInfo{}, &info) }()
+	<-sent
+
+	// the reply is announced, but its data never arrives
+	var buf bytes.Buffer
+	w := ProtocolWriter{w: &buf}
+	w.uint32(100)
+	w.uint32(0xFFFFFFFF)
+	w.uint64(0)
+	w.uint32(0)
+	w.byte('L')
+	w.uint32(OpReply)
+	w.byte('L')
+	w.uint32(0)
+	w.byte('t')
+	w.flush()
+	go pw.Write(buf.Bytes())
+
+	select {
+	case err := <-reply:
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("RequestContext didn't return after the context was done")
+	}
+}
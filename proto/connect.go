@@ -1,6 +1,7 @@
 package proto
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -19,6 +20,19 @@ import (
 // https://www.freedesktop.org/wiki/Software/PulseAudio/Documentation/User/ServerStrings/
 // If the server string is empty, the environment variable PULSE_SERVER will be used.
 func Connect(server string) (*Client, net.Conn, error) {
+	return ConnectCookie(server, nil)
+}
+
+// ConnectCookie is like Connect, but uses the given authentication cookie.
+// If cookie is nil, the cookie is read from the file named by the environment variable PULSE_COOKIE,
+// or from ~/.config/pulse/cookie.
+func ConnectCookie(server string, cookie []byte) (*Client, net.Conn, error) {
+	return ConnectContext(context.Background(), server, cookie)
+}
+
+// ConnectContext is like ConnectCookie, but gives up when the context is done.
+// The context only applies to establishing the connection, not to the returned client.
+func ConnectContext(ctx context.Context, server string, cookie []byte) (*Client, net.Conn, error) {
 	var sstr []serverString
 	if server != "" {
 		sstr = parseServerString(server)
@@ -40,39 +54,38 @@ func Connect(server string) (*Client, net.Conn, error) {
 	}
 
 	var lastErr error
+	var dialer net.Dialer
 	for _, s := range sstr {
 		if s.localname != "" && localname != s.localname {
 			continue
 		}
-		conn, err := net.Dial(s.protocol, s.addr)
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		conn, err := dialer.DialContext(ctx, s.protocol, s.addr)
 		if err != nil {
 			lastErr = err
 			continue
 		}
 		c.Open(conn)
 
-		cookiePath := os.Getenv("HOME") + "/.config/pulse/cookie"
-		if path, ok := os.LookupEnv("PULSE_COOKIE"); ok {
-			cookiePath = path
-		}
-
-		cookie, err := ioutil.ReadFile(cookiePath)
-		if err != nil {
-			if !os.IsNotExist(err) {
+		cookie := cookie
+		if cookie == nil {
+			cookie, err = readCookie()
+			if err != nil {
 				conn.Close()
 				lastErr = err
 				continue
 			}
-			// If the server is launched with auth-anonymous=1,
-			// any 256 bytes cookie will be accepted.
-			cookie = make([]byte, 256)
 		}
 		var authReply AuthReply
-		err = c.Request(
+		authCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		err = c.RequestContext(authCtx,
 			&Auth{
 				Version: c.Version(),
 				Cookie:  cookie,
 			}, &authReply)
+		cancel()
 		if err != nil {
 			conn.Close()
 			lastErr = err
@@ -86,6 +99,29 @@ func Connect(server string) (*Client, net.Conn, error) {
 	return nil, nil, lastErr
 }
 
+func readCookie() ([]byte, error) {
+	var cookiePath string
+	if path, ok := os.LookupEnv("PULSE_COOKIE"); ok {
+		cookiePath = path
+	} else if home := os.Getenv("HOME"); home != "" {
+		cookiePath = home + "/.config/pulse/cookie"
+	} else {
+		// Without a home directory there is no cookie file, e.g. inside containers.
+		return make([]byte, 256), nil
+	}
+
+	cookie, err := ioutil.ReadFile(cookiePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		// If the server is launched with auth-anonymous=1,
+		// any 256 bytes cookie will be accepted.
+		cookie = make([]byte, 256)
+	}
+	return cookie, nil
+}
+
 type serverString struct {
 	localname string
 	protocol  string
@@ -106,7 +142,9 @@ func parseServerString(str string) []serverString {
 		case len(s) == 0:
 			// no server string
 			continue
-		case s[0] == '/':
+		case s[0] == '/', s[0] == '@':
+			// A leading @ denotes a socket in the abstract namespace (linux only),
+			// the net package translates it to the leading null byte.
 			server.protocol = "unix"
 			server.addr = s
 		case strings.HasPrefix(s, "unix:"):
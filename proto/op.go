@@ -689,6 +689,13 @@ type UpdateRecordStreamSampleRate struct {
 	SampleRate  uint32
 }
 
+// Modes for the Update*Proplist requests.
+const (
+	UpdateSet     = 0 // replace the entire property list
+	UpdateMerge   = 1 // add new properties, but keep the values of existing ones
+	UpdateReplace = 2 // add new properties and replace the values of existing ones
+)
+
 type UpdatePlaybackStreamProplist struct {
 	StreamIndex uint32
 	Mode        uint32
@@ -708,14 +715,14 @@ type UpdateClientProplist struct {
 
 type RemovePlaybackStreamProplist struct {
 	StreamIndex uint32
-	Properties  PropList // ignored
+	Keys        []string
 }
 type RemoveRecordStreamProplist struct {
 	StreamIndex uint32
-	Properties  PropList // ignored
+	Keys        []string
 }
 type RemoveClientProplist struct {
-	Properties PropList // ignored
+	Keys []string
 }
 
 type SetDefaultSink struct{ SinkName string }
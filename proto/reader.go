@@ -15,7 +15,7 @@ type ProtocolReader struct {
 }
 
 func (p *ProtocolReader) setErr(err error) {
-	if p.err != nil {
+	if p.err == nil {
 		p.err = err
 	}
 }
@@ -111,7 +111,7 @@ func (p *ProtocolReader) string() string {
 			return s
 		}
 	}
-	p.setErr(ErrProtocolError)
+	p.setErr(ErrProtocol)
 	return ""
 }
 
@@ -134,6 +134,10 @@ func (p *ProtocolReader) tmpbytes(n int) []byte {
 
 func (p *ProtocolReader) x() []byte {
 	l := p.uint32()
+	if l > maxFrameSize {
+		p.setErr(ErrProtocol)
+		return nil
+	}
 	x := make([]byte, l)
 	p.fill(int(l))
 	if p.err != nil {
@@ -151,24 +155,24 @@ func (p *ProtocolReader) propList(out PropList) {
 			break
 		}
 		if keyType != 't' {
-			p.setErr(ErrProtocolError)
+			p.setErr(ErrProtocol)
 			return
 		}
 		key := p.string()
 		lenType := p.byte()
 		if lenType != 'L' {
-			p.setErr(ErrProtocolError)
+			p.setErr(ErrProtocol)
 			return
 		}
 		l := p.uint32()
 		valueType := p.byte()
 		if valueType != 'x' {
-			p.setErr(ErrProtocolError)
+			p.setErr(ErrProtocol)
 			return
 		}
 		value := p.x()
 		if len(value) != int(l) {
-			p.setErr(ErrProtocolError)
+			p.setErr(ErrProtocol)
 			return
 		}
 		out[key] = PropListEntry(value)
@@ -235,6 +239,10 @@ func (p *ProtocolReader) value(i interface{}, version Version) {
 		case 'x':
 			if f.Kind() == reflect.String {
 				x := p.x()
+				if len(x) == 0 {
+					p.setErr(ErrProtocol)
+					return
+				}
 				f.SetString(string(x[:len(x)-1]))
 			} else {
 				f.SetBytes(p.x())
@@ -270,6 +278,9 @@ func (p *ProtocolReader) value(i interface{}, version Version) {
 			p.byte() // P
 			p.propList(m)
 			f.Set(reflect.ValueOf(FormatInfo{enc, m}))
+		default:
+			p.setErr(ErrProtocol)
+			return
 		}
 	}
 }
@@ -1,7 +1,11 @@
 package proto
 
 import (
+	"net"
+	"os"
 	"reflect"
+	"runtime"
+	"strconv"
 	"testing"
 )
 
@@ -16,6 +20,18 @@ func TestParseServerString(t *testing.T) {
 				{"", "unix", "/path/to/socket"},
 			},
 		},
+		{
+			"unix:@pulse/native",
+			[]serverString{
+				{"", "unix", "@pulse/native"},
+			},
+		},
+		{
+			"@pulse/native",
+			[]serverString{
+				{"", "unix", "@pulse/native"},
+			},
+		},
 		{
 			"tcp4:host:port",
 			[]serverString{
@@ -49,3 +65,31 @@ func TestParseServerString(t *testing.T) {
 		}
 	}
 }
+
+func TestDialAbstractSocket(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract sockets are only supported on linux")
+	}
+	name := "@pulse-test-" + strconv.Itoa(os.Getpid())
+	l, err := net.Listen("unix", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	s := parseServerString("unix:" + name)
+	if len(s) != 1 {
+		t.Fatalf("Expected one server, but got: %+v", s)
+	}
+	conn, err := net.Dial(s[0].protocol, s[0].addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
@@ -5,13 +5,19 @@ import "math"
 const Undefined = 0xFFFFFFFF
 
 const (
-	FormatUint8     = 0
-	FormatInt16LE   = 3
-	FormatInt16BE   = 4
-	FormatFloat32LE = 5
-	FormatFloat32BE = 6
-	FormatInt32LE   = 7
-	FormatInt32BE   = 8
+	FormatUint8      = 0
+	FormatALaw       = 1
+	FormatMuLaw      = 2
+	FormatInt16LE    = 3
+	FormatInt16BE    = 4
+	FormatFloat32LE  = 5
+	FormatFloat32BE  = 6
+	FormatInt32LE    = 7
+	FormatInt32BE    = 8
+	FormatInt24LE    = 9
+	FormatInt24BE    = 10
+	FormatInt24_32LE = 11
+	FormatInt24_32BE = 12
 )
 
 const (
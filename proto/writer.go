@@ -1,9 +1,12 @@
 package proto
 
 import (
+	"errors"
 	"io"
 	"reflect"
 	"strconv"
+	"syscall"
+	"time"
 )
 
 type ProtocolWriter struct {
@@ -14,7 +17,7 @@ type ProtocolWriter struct {
 }
 
 func (p *ProtocolWriter) setErr(err error) {
-	if p.err != nil {
+	if p.err == nil {
 		p.err = err
 	}
 }
@@ -193,6 +196,12 @@ func (p *ProtocolWriter) value(i interface{}, version Version) {
 			p.byte(f.Encoding)
 			p.byte('P')
 			p.propList(f.Properties)
+		case []string:
+			for _, s := range f {
+				p.byte('t')
+				p.string(s)
+			}
+			p.byte('N')
 		case []FormatInfo:
 			p.byte('B')
 			p.byte(byte(len(f)))
@@ -206,3 +215,42 @@ func (p *ProtocolWriter) value(i interface{}, version Version) {
 		}
 	}
 }
+
+// retryWriter retries writes that fail with EAGAIN, which happens if the underlying socket is non-blocking.
+// If the socket provides its file descriptor, the rest of the data is written directly once it is writable,
+// otherwise the writes are retried with increasing delays.
+type retryWriter struct {
+	w io.Writer
+}
+
+const (
+	retryMinDelay = 50 * time.Microsecond
+	retryMaxDelay = 10 * time.Millisecond
+)
+
+func (r retryWriter) Write(b []byte) (int, error) {
+	written := 0
+	delay := retryMinDelay
+	for written < len(b) {
+		n, err := r.w.Write(b[written:])
+		written += n
+		if err != nil {
+			if !errors.Is(err, syscall.EAGAIN) && !errors.Is(err, syscall.EWOULDBLOCK) {
+				return written, err
+			}
+			m, ok, err := writeRaw(r.w, b[written:])
+			written += m
+			if ok {
+				return written, err
+			}
+			// There is no way to wait until the socket is writable, so back off and try again.
+			time.Sleep(delay)
+			if delay < retryMaxDelay {
+				delay *= 2
+			}
+			continue
+		}
+		delay = retryMinDelay
+	}
+	return written, nil
+}
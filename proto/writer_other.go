@@ -0,0 +1,11 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package proto
+
+import "io"
+
+// writeRaw is only implemented for unix systems.
+func writeRaw(w io.Writer, b []byte) (written int, ok bool, err error) {
+	return 0, false, nil
+}
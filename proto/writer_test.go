@@ -0,0 +1,48 @@
+package proto
+
+import (
+	"bytes"
+	"syscall"
+	"testing"
+)
+
+// eagainWriter simulates a non-blocking socket that only accepts a few bytes at a time.
+// It doesn't implement syscall.Conn, so the writes are retried after a delay.
+type eagainWriter struct {
+	bytes.Buffer
+	calls int
+}
+
+func (w *eagainWriter) Write(b []byte) (int, error) {
+	w.calls++
+	if w.calls%2 == 0 {
+		return 0, syscall.EAGAIN
+	}
+	if len(b) > 3 {
+		w.Buffer.Write(b[:3])
+		return 3, syscall.EAGAIN
+	}
+	return w.Buffer.Write(b)
+}
+
+func TestSendEAGAIN(t *testing.T) {
+	var w eagainWriter
+	var c Client
+	c.w.w = retryWriter{&w}
+	data := []byte("some audio data")
+	if err := c.Send(7, data); err != nil {
+		t.Fatal(err)
+	}
+
+	var expected bytes.Buffer
+	e := ProtocolWriter{w: &expected}
+	e.uint32(uint32(len(data)))
+	e.uint32(7)
+	e.uint64(0)
+	e.uint32(0)
+	e.flush()
+	expected.Write(data)
+	if !bytes.Equal(w.Bytes(), expected.Bytes()) {
+		t.Errorf("expected %x, got %x", expected.Bytes(), w.Bytes())
+	}
+}
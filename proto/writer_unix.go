@@ -0,0 +1,49 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package proto
+
+import (
+	"io"
+	"syscall"
+)
+
+// writeRaw writes b directly to the file descriptor of w, using the runtime's poller to wait while it isn't writable.
+// ok is false if w doesn't provide a pollable file descriptor, the caller has to wait some other way.
+func writeRaw(w io.Writer, b []byte) (written int, ok bool, err error) {
+	sc, isConn := w.(syscall.Conn)
+	if !isConn {
+		return 0, false, nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return 0, false, nil
+	}
+	var werr error
+	err = rc.Write(func(fd uintptr) bool {
+		for written < len(b) {
+			n, err := syscall.Write(int(fd), b[written:])
+			if n > 0 {
+				written += n
+			}
+			switch err {
+			case nil:
+			case syscall.EINTR:
+			case syscall.EAGAIN:
+				return false
+			default:
+				werr = err
+				return true
+			}
+		}
+		return true
+	})
+	if werr != nil {
+		return written, true, werr
+	}
+	if err != nil {
+		// the descriptor can't be polled
+		return written, false, nil
+	}
+	return written, true, nil
+}
@@ -0,0 +1,80 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package proto
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// fdWriter writes to a non-blocking socket without going through the runtime's poller, so EAGAIN is returned to the caller.
+type fdWriter struct {
+	fd      int
+	f       *os.File
+	eagain  bool
+	retries int // writes after the first EAGAIN
+}
+
+func (w *fdWriter) Write(b []byte) (int, error) {
+	if w.eagain {
+		w.retries++
+	}
+	n, err := syscall.Write(w.fd, b)
+	if err == syscall.EAGAIN {
+		w.eagain = true
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n, err
+}
+
+func (w *fdWriter) SyscallConn() (syscall.RawConn, error) { return w.f.SyscallConn() }
+
+func TestSendNonBlockingSocket(t *testing.T) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fd := range fds {
+		if err := syscall.SetNonblock(fd, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wf := os.NewFile(uintptr(fds[0]), "socket")
+	rf := os.NewFile(uintptr(fds[1]), "socket")
+	defer wf.Close()
+	defer rf.Close()
+	// calling Fd would put the socket into blocking mode, so the descriptor is kept separately
+	w := &fdWriter{fd: fds[0], f: wf}
+
+	var c Client
+	c.w.w = retryWriter{w}
+	data := make([]byte, 4<<20)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	received := make(chan []byte)
+	go func() {
+		b := make([]byte, len(data)+20)
+		n, _ := io.ReadFull(rf, b)
+		received <- b[:n]
+	}()
+	if err := c.Send(7, data); err != nil {
+		t.Fatal(err)
+	}
+	got := <-received
+	if !w.eagain {
+		t.Error("the socket buffer never filled up")
+	}
+	if w.retries != 0 {
+		t.Errorf("expected the rest to be written once the socket is writable, got %d retries", w.retries)
+	}
+	if len(got) != len(data)+20 || !bytes.Equal(got[20:], data) {
+		t.Errorf("received %d bytes, expected %d", len(got), len(data)+20)
+	}
+}
@@ -1,20 +1,45 @@
 package pulse
 
-import "github.com/jfreymuth/pulse/proto"
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jfreymuth/pulse/proto"
+)
 
 // A RecordStream is used for recording audio.
 // When creating a stream, the user must provide a callback that will be called with the recorded audio data.
+//
+// Start, Stop and Close, as well as the methods that report the stream's state, may be called from any goroutine.
+// The callback runs on the client's read goroutine, or on a separate goroutine if RecordOnOverflow is used.
 type RecordStream struct {
 	c *Client
 
 	index uint32
-	state streamState
-	err   error
 
-	w Writer
+	mu       sync.Mutex
+	state    streamState // protected by mu
+	err      error       // protected by mu
+	written  int64       // protected by mu
+	overflow bool        // protected by mu
+	restore  bool        // protected by mu
+	ended    bool        // protected by mu, see end
+
+	corkMu sync.Mutex // serializes cork requests with the state changes that cause them, see corkIdle
+
+	w    Writer
+	done chan struct{} // closed by end, it is kept when the stream is restored after a reconnect
+
+	maxBytes int64
+
+	onOverflow func()
+	queue      chan []byte
+	quit       chan struct{}
 
 	createRequest  proto.CreateRecordStream
-	createReply    proto.CreateRecordStreamReply
+	createReply    proto.CreateRecordStreamReply // protected by mu, see reply
 	bytesPerSample int
 }
 
@@ -23,6 +48,16 @@ type RecordStream struct {
 // The created stream wil not be running, it must be started with Start().
 // The order of options is important in some cases, see the documentation of the individual RecordOptions.
 func (c *Client) NewRecord(w Writer, opts ...RecordOption) (*RecordStream, error) {
+	r := c.newRecord(w, opts)
+	err := r.create()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// newRecord applies the options, but doesn't create the stream.
+func (c *Client) newRecord(w Writer, opts []RecordOption) *RecordStream {
 	r := &RecordStream{
 		c: c,
 		createRequest: proto.CreateRecordStream{
@@ -37,6 +72,7 @@ func (c *Client) NewRecord(w Writer, opts ...RecordOption) (*RecordStream, error
 		},
 		bytesPerSample: bytes(w.Format()),
 		w:              w,
+		done:           make(chan struct{}),
 	}
 
 	for _, opt := range opts {
@@ -50,76 +86,466 @@ func (c *Client) NewRecord(w Writer, opts ...RecordOption) (*RecordStream, error
 		}
 		r.createRequest.ChannelVolumes = cvol
 	}
+	return r
+}
 
-	err := c.c.Request(&r.createRequest, &r.createReply)
+// create creates the stream on the server and registers it with the client.
+func (r *RecordStream) create() error {
+	var reply proto.CreateRecordStreamReply
+	err := r.c.c().Request(&r.createRequest, &reply)
 	if err != nil {
+		return err
+	}
+	r.index = reply.StreamIndex
+	r.mu.Lock()
+	r.createReply = reply
+	r.state = idle
+	r.err = nil
+	r.mu.Unlock()
+	if r.onOverflow != nil {
+		// the previous deliver goroutine, if any, was stopped when the stream was closed or lost
+		r.queue = make(chan []byte, recordQueueLength)
+		r.quit = make(chan struct{})
+		go r.deliver(r.queue, r.quit)
+	}
+	r.c.mu.Lock()
+	r.c.record[r.index] = r
+	r.c.mu.Unlock()
+	return nil
+}
+
+// reply returns a copy of the server's reply to the create request, with the updates made by SetBufferAttr.
+func (r *RecordStream) reply() proto.CreateRecordStreamReply {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.createReply
+}
+
+// RecordBuffer records audio for the duration d and returns it together with its sample spec.
+// The audio is recorded in the native-endian 16 bit format unless the options specify otherwise.
+func (c *Client) RecordBuffer(d time.Duration, opts ...RecordOption) ([]byte, proto.SampleSpec, error) {
+	var buf []byte
+	size := 0
+	done := make(chan struct{})
+	w := Uint8Writer(func(b []byte) (int, error) {
+		n := size - len(buf)
+		if n > len(b) {
+			n = len(b)
+		}
+		buf = append(buf, b[:n]...)
+		if len(buf) >= size {
+			close(done)
+			return n, EndOfData
+		}
+		return n, nil
+	})
+	r, err := c.NewRecord(NewWriter(w, formatI16), opts...)
+	if err != nil {
+		return nil, proto.SampleSpec{}, err
+	}
+	defer r.Close()
+	spec := r.SampleSpec()
+	frame := int(spec.Channels) * bytes(spec.Format)
+	size = int(d.Seconds()*float64(spec.Rate)) * frame
+	if size == 0 {
+		return nil, spec, nil
+	}
+	buf = make([]byte, 0, size)
+
+	r.Start()
+	select {
+	case <-done:
+		return buf, spec, nil
+	case <-time.After(d + time.Second):
+		if r.Closed() {
+			return nil, spec, ErrConnectionClosed
+		}
+		if err := r.Error(); err != nil {
+			return nil, spec, err
+		}
+		return nil, spec, errRecordTimeout
+	}
+}
+
+const errRecordTimeout = pulseError("pulse: recording timed out")
+
+// NewRecordContext creates a record stream that is closed when the context is done.
+// If the context is done before the server has created the stream, NewRecordContext returns the context's error
+// and the stream is deleted as soon as the server has created it.
+// See NewRecord for details.
+func (c *Client) NewRecordContext(ctx context.Context, w Writer, opts ...RecordOption) (*RecordStream, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	r.index = r.createReply.StreamIndex
-	c.mu.Lock()
-	c.record[r.index] = r
-	c.mu.Unlock()
+	r := c.newRecord(w, opts)
+	done := make(chan error, 1)
+	go func() { done <- r.create() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+	case <-ctx.Done():
+		go func() {
+			if <-done == nil {
+				r.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.Close()
+		case <-r.done:
+		}
+	}()
 	return r, nil
 }
 
+// RecordToWriter records audio and writes it to w until the context is done.
+// If the RecordMaxBytes option is used, recording stops once that many bytes have been written.
+// It returns the number of bytes written and the first error returned by w, if any.
+// Cancellation of the context and reaching the byte limit are not considered errors.
+//
+// The audio is recorded in the native-endian 16 bit format unless the options specify otherwise.
+func (c *Client) RecordToWriter(ctx context.Context, w io.Writer, opts ...RecordOption) (int64, error) {
+	var mu sync.Mutex
+	var written int64
+	var werr error
+	var r *RecordStream
+	stop := make(chan struct{})
+	rw := Uint8Writer(func(b []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if werr != nil {
+			return 0, werr
+		}
+		n, err := w.Write(b)
+		written += int64(n)
+		if err == nil && r.maxBytes > 0 && written >= r.maxBytes {
+			err = EndOfData
+		}
+		if err != nil {
+			werr = err
+			close(stop)
+		}
+		return n, err
+	})
+	r, err := c.NewRecord(NewWriter(rw, formatI16), opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	r.Start()
+	select {
+	case <-ctx.Done():
+	case <-stop:
+	case <-r.done:
+		err = ErrConnectionClosed
+	}
+	r.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if werr != nil && werr != EndOfData {
+		err = werr
+	}
+	return written, err
+}
+
+// NewSpeechRecord creates a record stream suitable for speech recognition.
+// The stream records a single channel of 16 bit samples at 16000 Hz, the server will resample the audio if necessary.
+// If source is nil, the default source is used.
+// The callback is called with the recorded samples, the slice must not be used after the callback returns.
+func (c *Client) NewSpeechRecord(cb func([]int16), source *Source) (*RecordStream, error) {
+	w := Int16Writer(func(buf []int16) (int, error) {
+		cb(buf)
+		return len(buf), nil
+	})
+	opts := []RecordOption{RecordMono, RecordSampleRate(16000)}
+	if source != nil {
+		opts = append(opts, RecordSource(source))
+	}
+	return c.NewRecord(w, opts...)
+}
+
+// recordQueueLength is the number of packets that are buffered for streams using RecordOnOverflow.
+const recordQueueLength = 32
+
 func (r *RecordStream) write(buf []byte) {
+	if r.queue == nil {
+		r.writeData(buf)
+		return
+	}
+	select {
+	case r.queue <- append([]byte(nil), buf...):
+	default:
+		r.mu.Lock()
+		r.overflow = true
+		r.mu.Unlock()
+		r.onOverflow()
+	}
+}
+
+// deliver passes queued data to the writer until quit is closed.
+func (r *RecordStream) deliver(queue <-chan []byte, quit <-chan struct{}) {
+	for {
+		select {
+		case buf := <-queue:
+			r.writeData(buf)
+		case <-quit:
+			return
+		}
+	}
+}
+
+func (r *RecordStream) writeData(buf []byte) {
+	r.mu.Lock()
 	if r.err != nil {
+		r.mu.Unlock()
 		return
 	}
-	_, err := r.w.Write(buf)
+	if r.maxBytes > 0 {
+		if rem := r.maxBytes - r.written; int64(len(buf)) > rem {
+			buf = buf[:rem]
+		}
+	}
+	r.mu.Unlock()
+	n, err := r.w.Write(buf)
+	r.mu.Lock()
+	r.written += int64(n)
+	if err == nil && r.maxBytes > 0 && r.written >= r.maxBytes {
+		err = EndOfData
+	}
+	stopped := false
 	if err != nil {
 		r.err = err
-		go r.Stop()
+		if r.state == running {
+			r.state = idle
+			stopped = true
+		}
+	}
+	r.mu.Unlock()
+	if stopped {
+		// this runs on the client's read goroutine, which can't wait for the reply
+		go r.corkIdle()
+	}
+}
+
+// corkIdle corks a stream that was stopped because its writer returned an error,
+// unless it was started again or closed in the meantime. Closed streams are never corked.
+func (r *RecordStream) corkIdle() {
+	r.corkMu.Lock()
+	defer r.corkMu.Unlock()
+	r.mu.Lock()
+	idle := r.state == idle
+	r.mu.Unlock()
+	if idle {
+		r.c.c().Request(&proto.CorkRecordStream{StreamIndex: r.index, Corked: true}, nil)
 	}
 }
 
 // Start starts recording audio.
 func (r *RecordStream) Start() {
-	if r.state == idle {
-		r.err = nil
-		r.c.c.Request(&proto.FlushRecordStream{StreamIndex: r.index}, nil)
-		r.c.c.Request(&proto.CorkRecordStream{StreamIndex: r.index, Corked: false}, nil)
-		r.state = running
+	r.corkMu.Lock()
+	defer r.corkMu.Unlock()
+	r.mu.Lock()
+	if r.state != idle {
+		r.mu.Unlock()
+		return
 	}
+	r.err = nil
+	r.written = 0
+	r.overflow = false
+	r.state = running
+	r.mu.Unlock()
+	r.c.c().Request(&proto.FlushRecordStream{StreamIndex: r.index}, nil)
+	r.c.c().Request(&proto.CorkRecordStream{StreamIndex: r.index, Corked: false}, nil)
 }
 
 // Stop stops recording audio; the callback will no longer be called.
+// Calling Stop on a stream that is not running, in particular a closed stream, does nothing.
 func (r *RecordStream) Stop() {
-	if r.state == running {
-		r.c.c.Request(&proto.CorkRecordStream{StreamIndex: r.index, Corked: true}, nil)
-		r.state = idle
+	r.corkMu.Lock()
+	defer r.corkMu.Unlock()
+	r.mu.Lock()
+	if r.state != running {
+		r.mu.Unlock()
+		return
 	}
+	r.state = idle
+	r.mu.Unlock()
+	r.c.c().Request(&proto.CorkRecordStream{StreamIndex: r.index, Corked: true}, nil)
 }
 
 // Close closes the stream.
 func (r *RecordStream) Close() {
-	if !r.Closed() {
-		r.c.c.Request(&proto.DeleteRecordStream{StreamIndex: r.index}, nil)
-		r.state = closed
-		r.c.mu.Lock()
-		delete(r.c.record, r.index)
-		r.c.mu.Unlock()
+	// a pending corkIdle must not send its request after the stream was deleted
+	r.corkMu.Lock()
+	defer r.corkMu.Unlock()
+	r.mu.Lock()
+	if r.state == closed || r.state == serverLost {
+		r.mu.Unlock()
+		return
+	}
+	r.state = closed
+	r.mu.Unlock()
+	r.c.c().Request(&proto.DeleteRecordStream{StreamIndex: r.index}, nil)
+	r.c.mu.Lock()
+	delete(r.c.record, r.index)
+	r.c.mu.Unlock()
+	r.end()
+	if r.quit != nil {
+		close(r.quit)
+	}
+}
+
+// end marks the stream as finished for good and closes r.done.
+// Streams that are lost with the connection are only ended if they won't be restored.
+func (r *RecordStream) end() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.ended {
+		r.ended = true
+		close(r.done)
+	}
+}
+
+// lost marks the stream as lost with the connection to the server.
+func (r *RecordStream) lost() {
+	r.mu.Lock()
+	if r.state == closed || r.state == serverLost {
+		r.mu.Unlock()
+		return
+	}
+	r.restore = r.state == running
+	r.err = ErrConnectionClosed
+	r.state = serverLost
+	r.mu.Unlock()
+	if r.quit != nil {
+		close(r.quit)
 	}
 }
 
 // Closed returns wether the stream was closed.
 // Calling other methods on a closed stream may panic.
-func (r *RecordStream) Closed() bool { return r.state == closed || r.state == serverLost }
+func (r *RecordStream) Closed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state == closed || r.state == serverLost
+}
 
 // Running returns wether the stream is currently recording.
-func (r *RecordStream) Running() bool { return r.state == running }
+func (r *RecordStream) Running() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state == running
+}
+
+// Overflow returns true if any recorded data was discarded since the last call to Start.
+// The server doesn't notify clients about overflows of record streams, so only data that was discarded
+// by the client is detected, see RecordOnOverflow. Without that option, Overflow always returns false.
+func (r *RecordStream) Overflow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.overflow
+}
 
 // Error returns the last error returned by the stream's writer.
-func (r *RecordStream) Error() error { return r.err }
+func (r *RecordStream) Error() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
 
 // SampleRate returns the stream's sample rate (samples per second).
 func (r *RecordStream) SampleRate() int {
-	return int(r.createReply.Rate)
+	return int(r.reply().Rate)
 }
 
 // Channels returns the number of channels.
 func (r *RecordStream) Channels() int {
-	return int(r.createReply.Channels)
+	return int(r.reply().Channels)
+}
+
+// SampleSpec returns the stream's sample format, rate and number of channels.
+func (r *RecordStream) SampleSpec() proto.SampleSpec {
+	return r.reply().SampleSpec
+}
+
+// Format returns the sample format the server uses for the stream, e.g. proto.FormatInt16LE.
+func (r *RecordStream) Format() byte {
+	return r.reply().Format
+}
+
+// BytesPerSample returns the size of a single sample of one channel in bytes.
+func (r *RecordStream) BytesPerSample() int {
+	return r.bytesPerSample
+}
+
+// BufferFragmentSize returns the fragment size in bytes, see RecordBufferFragmentSize.
+func (r *RecordStream) BufferFragmentSize() int {
+	return int(r.reply().BufferFragSize)
+}
+
+// RecordBufferAttr contains the buffer attributes of a record stream, in bytes.
+type RecordBufferAttr struct {
+	FragmentSize int
+	MaxLength    int
+}
+
+// SetBufferAttr changes the fragment size and maximum buffer length (both in bytes) of a running stream.
+// A negative value lets the server choose the value. The server may adjust the values,
+// SetBufferAttr returns the values that are actually used.
+func (r *RecordStream) SetBufferAttr(fragSize, maxLength int) (RecordBufferAttr, error) {
+	undefined := func(n int) uint32 {
+		if n < 0 {
+			return proto.Undefined
+		}
+		return uint32(n)
+	}
+	var reply proto.SetRecordStreamBufferAttrReply
+	err := r.c.c().Request(&proto.SetRecordStreamBufferAttr{
+		StreamIndex:     r.index,
+		BufferMaxLength: undefined(maxLength),
+		BufferFragSize:  undefined(fragSize),
+		AdjustLatency:   r.createRequest.AdjustLatency,
+	}, &reply)
+	if err != nil {
+		return RecordBufferAttr{}, err
+	}
+	r.mu.Lock()
+	r.createReply.BufferMaxLength = reply.BufferMaxLength
+	r.createReply.BufferFragSize = reply.BufferFragSize
+	r.createReply.SourceLatency = reply.SourceLatency
+	r.mu.Unlock()
+	return RecordBufferAttr{FragmentSize: int(reply.BufferFragSize), MaxLength: int(reply.BufferMaxLength)}, nil
+}
+
+// Latency returns the time between audio arriving at the source and being passed to the stream's writer.
+// It is calculated from the latency of the source and the amount of audio buffered by the server,
+// plus the time it takes to send the data to the client. If the source is the monitor of a sink,
+// the latency of the sink is included as well.
+// If the stream is not running, Latency returns 0.
+func (r *RecordStream) Latency() (time.Duration, error) {
+	if !r.Running() {
+		return 0, nil
+	}
+	var reply proto.GetRecordLatencyReply
+	start := time.Now()
+	err := r.c.c().Request(&proto.GetRecordLatency{StreamIndex: r.index}, &reply)
+	if err != nil {
+		return 0, err
+	}
+	// the data will take about half a round trip to arrive
+	transport := time.Since(start) / 2
+	queued := int(reply.WriteIndex - reply.ReadIndex)
+	if queued < 0 {
+		queued = 0
+	}
+	buffer := BytesToLatency(queued, r.SampleSpec())
+	source := time.Duration(reply.MonitorLatency+reply.Latency) * time.Microsecond
+	return source + time.Duration(buffer*float64(time.Second)) + transport, nil
 }
 
 // StreamIndex returns the stream index.
@@ -179,12 +605,33 @@ func RecordBufferFragmentSize(size uint32) RecordOption {
 // Fragment size and latency should not be set at the same time.
 func RecordLatency(seconds float64) RecordOption {
 	return func(r *RecordStream) {
-		r.createRequest.BufferFragSize = uint32(seconds*float64(r.createRequest.Rate)) * uint32(r.createRequest.Channels) * uint32(r.bytesPerSample)
+		r.createRequest.BufferFragSize = uint32(LatencyToBytes(seconds, r.createRequest.SampleSpec))
 		r.createRequest.BufferMaxLength = 2 * r.createRequest.BufferFragSize
 		r.createRequest.AdjustLatency = true
 	}
 }
 
+// RecordOnOverflow sets a function that is called when recorded data had to be discarded because the writer
+// didn't keep up.
+//
+// Normally the writer is called directly when data arrives, and delays in the writer delay all other
+// communication with the server. With this option, data is instead queued and passed to the writer on a
+// separate goroutine. If the queue is full, the data is discarded and f is called.
+// f is called on the client's read goroutine, it must return quickly and must not call methods of the client or its streams.
+func RecordOnOverflow(f func()) RecordOption {
+	return func(r *RecordStream) {
+		r.onOverflow = f
+	}
+}
+
+// RecordMaxBytes stops the stream after n bytes have been passed to the writer.
+// The limit applies each time the stream is started.
+func RecordMaxBytes(n int64) RecordOption {
+	return func(r *RecordStream) {
+		r.maxBytes = n
+	}
+}
+
 // RecordSource sets the source the stream should receive audio from.
 func RecordSource(source *Source) RecordOption {
 	return func(r *RecordStream) {
@@ -0,0 +1,89 @@
+package pulse
+
+import (
+	"io"
+	"sync"
+)
+
+// A RecordReader is a record stream that is read with calls to Read instead of a callback.
+// This allows e.g. copying recorded audio to a file using io.Copy.
+//
+// Recorded data is buffered until it is read, the buffer grows as necessary so that no audio is lost
+// if the reader falls behind.
+type RecordReader struct {
+	*RecordStream
+
+	mu      sync.Mutex
+	cond    sync.Cond
+	buf     []byte
+	started bool
+	closed  bool
+}
+
+// NewRecordReader creates a record stream that can be read from.
+// The data will be in the given format, see the constants defined in the proto package.
+// The stream is started by the first call to Read.
+func (c *Client) NewRecordReader(format byte, opts ...RecordOption) (*RecordReader, error) {
+	check(format)
+	rr := &RecordReader{}
+	rr.cond.L = &rr.mu
+	r, err := c.NewRecord(recordReaderWriter{rr, format}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	rr.RecordStream = r
+	go func() {
+		// done is only closed once the stream has ended for good, not when it is restored after a reconnect
+		<-r.done
+		rr.mu.Lock()
+		rr.closed = true
+		rr.cond.Broadcast()
+		rr.mu.Unlock()
+	}()
+	return rr, nil
+}
+
+// Read reads recorded audio, blocking until at least one byte is available.
+// After the stream is closed, Read returns the remaining buffered data, followed by io.EOF.
+// If the stream is restored after a reconnect, Read continues with the newly recorded data instead.
+func (rr *RecordReader) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	rr.mu.Lock()
+	if !rr.started && !rr.closed {
+		rr.started = true
+		rr.mu.Unlock()
+		rr.RecordStream.Start()
+		rr.mu.Lock()
+	}
+	defer rr.mu.Unlock()
+	for len(rr.buf) == 0 {
+		if rr.closed {
+			return 0, io.EOF
+		}
+		rr.cond.Wait()
+	}
+	n := copy(b, rr.buf)
+	rr.buf = rr.buf[n:]
+	return n, nil
+}
+
+func (rr *RecordReader) write(b []byte) {
+	rr.mu.Lock()
+	rr.buf = append(rr.buf, b...)
+	rr.cond.Broadcast()
+	rr.mu.Unlock()
+}
+
+type recordReaderWriter struct {
+	rr *RecordReader
+	f  byte
+}
+
+func (w recordReaderWriter) Write(b []byte) (int, error) {
+	w.rr.write(b)
+	return len(b), nil
+}
+
+func (w recordReaderWriter) Format() byte { return w.f }
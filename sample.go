@@ -0,0 +1,70 @@
+package pulse
+
+import "github.com/jfreymuth/pulse/proto"
+
+// maxUploadChunk is the maximum number of bytes sent in a single packet when uploading a sample.
+const maxUploadChunk = 64 * 1024
+
+// UploadSample stores a sample in the server's sample cache, so that it can be played with low latency using PlaySample.
+// data is raw audio in the format described by spec, it must contain a whole number of frames.
+// Mono and stereo samples are supported. If a sample with the same name already exists, it is replaced.
+func (c *Client) UploadSample(name string, spec proto.SampleSpec, data []byte) error {
+	m := defaultChannelMap(spec.Channels)
+	if !validSpec(spec, m) {
+		return errInvalidSampleSpec
+	}
+	frame := int(spec.Channels) * bytes(spec.Format)
+	if len(data) == 0 || len(data)%frame != 0 {
+		return errIncompleteFrame
+	}
+	var reply proto.CreateUploadStreamReply
+	err := c.c().Request(&proto.CreateUploadStream{
+		Name:       name,
+		SampleSpec: spec,
+		ChannelMap: m,
+		Length:     uint32(len(data)),
+		Properties: proto.PropList{},
+	}, &reply)
+	if err != nil {
+		return err
+	}
+	chunk := int(reply.Length)
+	if chunk <= 0 || chunk > maxUploadChunk {
+		chunk = maxUploadChunk
+	}
+	chunk -= chunk % frame
+	for len(data) > 0 {
+		n := len(data)
+		if n > chunk {
+			n = chunk
+		}
+		if err := c.c().Send(reply.StreamIndex, data[:n]); err != nil {
+			c.c().Request(&proto.DeleteUploadStream{StreamIndex: reply.StreamIndex}, nil)
+			return err
+		}
+		data = data[n:]
+	}
+	return c.c().Request(&proto.FinishUploadStream{StreamIndex: reply.StreamIndex}, nil)
+}
+
+const errIncompleteFrame = pulseError("pulse: sample data must contain whole frames")
+
+// PlaySample plays a sample from the server's sample cache on the given sink, or on the default sink if sink is nil.
+// The volume uses the same scale as (*PlaybackStream).SetVolume.
+func (c *Client) PlaySample(name string, sink *Sink, volume float64) error {
+	req := proto.PlaySample{
+		SinkIndex:  proto.Undefined,
+		Volume:     toVolume(volume),
+		Name:       name,
+		Properties: proto.PropList{},
+	}
+	if sink != nil {
+		req.SinkIndex = sink.info.SinkIndex
+	}
+	return c.c().Request(&req, nil)
+}
+
+// RemoveSample removes a sample from the server's sample cache.
+func (c *Client) RemoveSample(name string) error {
+	return c.c().Request(&proto.RemoveSample{Name: name}, nil)
+}
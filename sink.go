@@ -4,37 +4,50 @@ import "github.com/jfreymuth/pulse/proto"
 
 // A Sink is an output device.
 type Sink struct {
+	c    *Client
 	info proto.GetSinkInfoReply
 }
 
 // ListSinks returns a list of all available output devices.
 func (c *Client) ListSinks() ([]*Sink, error) {
 	var reply proto.GetSinkInfoListReply
-	err := c.c.Request(&proto.GetSinkInfoList{}, &reply)
+	err := c.c().Request(&proto.GetSinkInfoList{}, &reply)
 	if err != nil {
 		return nil, err
 	}
 	sinks := make([]*Sink, len(reply))
 	for i := range sinks {
-		sinks[i] = &Sink{info: *reply[i]}
+		sinks[i] = &Sink{c: c, info: *reply[i]}
 	}
 	return sinks, nil
 }
 
 // DefaultSink returns the default output device.
 func (c *Client) DefaultSink() (*Sink, error) {
-	var sink Sink
-	err := c.c.Request(&proto.GetSinkInfo{SinkIndex: proto.Undefined}, &sink.info)
+	sink := Sink{c: c}
+	err := c.c().Request(&proto.GetSinkInfo{SinkIndex: proto.Undefined}, &sink.info)
 	if err != nil {
 		return nil, err
 	}
 	return &sink, nil
 }
 
+// SetDefaultSink makes the sink the default sink.
+// Depending on the server version and configuration, existing streams may be moved to the new default.
+func (c *Client) SetDefaultSink(sink *Sink) error {
+	return c.c().Request(&proto.SetDefaultSink{SinkName: sink.info.SinkName}, nil)
+}
+
+// SinkByName looks up a sink by its name, as returned by ID.
+// If there is no such sink, the returned error is proto.ErrNoSuchEntity, which can be checked with errors.Is.
+func (c *Client) SinkByName(name string) (*Sink, error) {
+	return c.SinkByID(name)
+}
+
 // SinkByID looks up a sink id.
 func (c *Client) SinkByID(name string) (*Sink, error) {
-	var sink Sink
-	err := c.c.Request(&proto.GetSinkInfo{SinkIndex: proto.Undefined, SinkName: name}, &sink.info)
+	sink := Sink{c: c}
+	err := c.c().Request(&proto.GetSinkInfo{SinkIndex: proto.Undefined, SinkName: name}, &sink.info)
 	if err != nil {
 		return nil, err
 	}
@@ -66,3 +79,103 @@ func (s *Sink) SampleRate() int {
 func (s *Sink) SinkIndex() uint32 {
 	return s.info.SinkIndex
 }
+
+// IsFilter returns whether the sink is a filter sink, e.g. an equalizer, that processes audio and sends it to another sink.
+func (s *Sink) IsFilter() bool {
+	_, ok := s.info.Properties["device.master_device"]
+	return ok
+}
+
+// MasterSink returns the sink a filter sink sends its audio to.
+// If the sink is not a filter sink, MasterSink returns nil.
+func (s *Sink) MasterSink() (*Sink, error) {
+	master, ok := s.info.Properties["device.master_device"]
+	if !ok {
+		return nil, nil
+	}
+	return s.c.SinkByID(master.String())
+}
+
+// MonitorSource returns the sink's monitor source, which records the audio played by the sink.
+// It can be used with RecordSource, which is equivalent to using RecordMonitor with the sink.
+func (s *Sink) MonitorSource() (*Source, error) {
+	source := Source{c: s.c}
+	err := s.c.c().Request(&proto.GetSourceInfo{SourceIndex: s.info.MonitorSourceIndex}, &source.info)
+	if err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// SampleSpec returns the sink's sample format, rate and number of channels.
+func (s *Sink) SampleSpec() proto.SampleSpec {
+	return s.info.SampleSpec
+}
+
+// Description returns the human-readable description of the sink, e.g. "Built-in Audio Analog Stereo".
+// This is the same as Name.
+func (s *Sink) Description() string {
+	return s.info.Device
+}
+
+// ChannelMap returns the sink's channel map. This is the same as Channels.
+func (s *Sink) ChannelMap() proto.ChannelMap {
+	return s.info.ChannelMap
+}
+
+// Formats returns the formats supported by the sink.
+// For most sinks this only contains PCM (proto.EncodingPCM), other encodings are used for passthrough of compressed audio.
+func (s *Sink) Formats() []proto.FormatInfo {
+	return s.info.Formats
+}
+
+// SetVolume sets the volume of all channels of the sink.
+// The volume is mapped linearly onto the server's scale: 0 is muted, 1 is normal volume (100%),
+// higher values amplify the audio. Negative values are treated as 0, and very large values are limited
+// to the highest volume the server supports.
+func (s *Sink) SetVolume(v float64) error {
+	return s.c.c().Request(&proto.SetSinkVolume{
+		SinkIndex:      s.info.SinkIndex,
+		ChannelVolumes: channelVolumes(len(s.info.ChannelMap), v),
+	}, nil)
+}
+
+// Volume returns the current volume of the sink. If the channels have different volumes, the highest one is returned.
+// See SetVolume for the volume scale.
+func (s *Sink) Volume() (float64, error) {
+	if err := s.refresh(); err != nil {
+		return 0, err
+	}
+	return maxVolume(s.info.ChannelVolumes), nil
+}
+
+// Muted returns whether the sink is currently muted.
+func (s *Sink) Muted() (bool, error) {
+	if err := s.refresh(); err != nil {
+		return false, err
+	}
+	return s.info.Mute, nil
+}
+
+// SetMute mutes or unmutes the sink.
+// Muting does not change the volume, it is restored when the sink is unmuted.
+func (s *Sink) SetMute(mute bool) error {
+	return s.c.c().Request(&proto.SetSinkMute{SinkIndex: s.info.SinkIndex, Mute: mute}, nil)
+}
+
+// SetSuspended suspends or resumes the sink. A suspended sink releases the audio device, e.g. to save power.
+// Streams connected to the sink are paused while it is suspended.
+func (s *Sink) SetSuspended(suspend bool) error {
+	return s.c.c().Request(&proto.SuspendSink{SinkIndex: s.info.SinkIndex, Suspend: suspend}, nil)
+}
+
+// refresh requests the current state of the sink from the server.
+func (s *Sink) refresh() error {
+	var info proto.GetSinkInfoReply
+	err := s.c.c().Request(&proto.GetSinkInfo{SinkIndex: s.info.SinkIndex}, &info)
+	if err != nil {
+		return err
+	}
+	s.info = info
+	return nil
+}
@@ -0,0 +1,266 @@
+package pulse
+
+import "github.com/jfreymuth/pulse/proto"
+
+// A SinkInput is a playback stream of any client, including other applications.
+type SinkInput struct {
+	c    *Client
+	info proto.GetSinkInputInfoReply
+}
+
+// A SourceOutput is a record stream of any client, including other applications.
+type SourceOutput struct {
+	c    *Client
+	info proto.GetSourceOutputInfoReply
+}
+
+// ListSinkInputs returns a list of all playback streams on the server.
+func (c *Client) ListSinkInputs() ([]*SinkInput, error) {
+	var reply proto.GetSinkInputInfoListReply
+	err := c.c().Request(&proto.GetSinkInputInfoList{}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]*SinkInput, len(reply))
+	for i := range inputs {
+		inputs[i] = &SinkInput{c: c, info: *reply[i]}
+	}
+	return inputs, nil
+}
+
+// CorkStreamsByRole mutes or unmutes all playback streams with the given media role, e.g. to silence music during a phone call.
+// Clients can't cork streams of other clients, so like the role-cork module of the server,
+// this mutes the streams instead. Unmuting only affects streams that were muted by CorkStreamsByRole,
+// streams that were already muted stay muted.
+func (c *Client) CorkStreamsByRole(role string, corked bool) error {
+	inputs, err := c.ListSinkInputs()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	// forget streams that no longer exist, their index may be reused
+	exists := make(map[uint32]bool, len(inputs))
+	for _, s := range inputs {
+		exists[s.info.SinkInputIndex] = true
+	}
+	for index := range c.roleMuted {
+		if !exists[index] {
+			delete(c.roleMuted, index)
+		}
+	}
+	c.mu.Unlock()
+	for _, s := range inputs {
+		if s.Role() != role || s.info.Muted == corked {
+			continue
+		}
+		index := s.info.SinkInputIndex
+		c.mu.Lock()
+		mutedByUs := c.roleMuted[index]
+		c.mu.Unlock()
+		if !corked && !mutedByUs {
+			continue
+		}
+		if err := s.SetMute(corked); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		if corked {
+			if c.roleMuted == nil {
+				c.roleMuted = make(map[uint32]bool)
+			}
+			c.roleMuted[index] = true
+		} else {
+			delete(c.roleMuted, index)
+		}
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// ListSourceOutputs returns a list of all record streams on the server.
+func (c *Client) ListSourceOutputs() ([]*SourceOutput, error) {
+	var reply proto.GetSourceOutputInfoListReply
+	err := c.c().Request(&proto.GetSourceOutputInfoList{}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]*SourceOutput, len(reply))
+	for i := range outputs {
+		outputs[i] = &SourceOutput{c: c, info: *reply[i]}
+	}
+	return outputs, nil
+}
+
+// SinkInputIndex returns the sink input index.
+// This should only be used together with (*Client).RawRequest.
+func (s *SinkInput) SinkInputIndex() uint32 {
+	return s.info.SinkInputIndex
+}
+
+// MediaName returns the name of the stream, e.g. the title of the song that is being played.
+func (s *SinkInput) MediaName() string {
+	return s.info.MediaName
+}
+
+// ClientName returns the name of the application that owns the stream, or an empty string if it is unknown.
+func (s *SinkInput) ClientName() string {
+	return propString(s.info.Properties, "application.name")
+}
+
+// SinkIndex returns the index of the sink the stream plays to.
+func (s *SinkInput) SinkIndex() uint32 {
+	return s.info.SinkIndex
+}
+
+// Role returns the media role of the stream, e.g. "music" or "phone", or an empty string if it is unknown.
+func (s *SinkInput) Role() string {
+	return propString(s.info.Properties, "media.role")
+}
+
+// Corked returns whether the stream was corked by its application.
+// The value is not updated automatically, call ListSinkInputs again to get the current state.
+func (s *SinkInput) Corked() bool {
+	return s.info.Corked
+}
+
+// SetVolume sets the volume of all channels of the stream.
+// See (*Sink).SetVolume for the volume scale.
+func (s *SinkInput) SetVolume(v float64) error {
+	return s.c.c().Request(&proto.SetSinkInputVolume{
+		SinkInputIndex: s.info.SinkInputIndex,
+		ChannelVolumes: channelVolumes(len(s.info.ChannelMap), v),
+	}, nil)
+}
+
+// Volume returns the current volume of the stream. If the channels have different volumes, the highest one is returned.
+func (s *SinkInput) Volume() (float64, error) {
+	if err := s.refresh(); err != nil {
+		return 0, err
+	}
+	return maxVolume(s.info.ChannelVolumes), nil
+}
+
+// SetMute mutes or unmutes the stream.
+func (s *SinkInput) SetMute(mute bool) error {
+	return s.c.c().Request(&proto.SetSinkInputMute{SinkInputIndex: s.info.SinkInputIndex, Mute: mute}, nil)
+}
+
+// Muted returns whether the stream is currently muted.
+func (s *SinkInput) Muted() (bool, error) {
+	if err := s.refresh(); err != nil {
+		return false, err
+	}
+	return s.info.Muted, nil
+}
+
+// MoveTo moves the stream to a different sink.
+func (s *SinkInput) MoveTo(sink *Sink) error {
+	err := s.c.c().Request(&proto.MoveSinkInput{SinkInputIndex: s.info.SinkInputIndex, DeviceIndex: sink.info.SinkIndex}, nil)
+	if err != nil {
+		return err
+	}
+	s.info.SinkIndex = sink.info.SinkIndex
+	return nil
+}
+
+// Kill forcibly closes the stream. The application that owns it is notified that the stream was killed.
+func (s *SinkInput) Kill() error {
+	return s.c.c().Request(&proto.KillSinkInput{SinkInputIndex: s.info.SinkInputIndex}, nil)
+}
+
+// refresh requests the current state of the stream from the server.
+func (s *SinkInput) refresh() error {
+	var info proto.GetSinkInputInfoReply
+	err := s.c.c().Request(&proto.GetSinkInputInfo{SinkInputIndex: s.info.SinkInputIndex}, &info)
+	if err != nil {
+		return err
+	}
+	s.info = info
+	return nil
+}
+
+// SourceOutputIndex returns the source output index.
+// This should only be used together with (*Client).RawRequest.
+func (s *SourceOutput) SourceOutputIndex() uint32 {
+	return s.info.SourceOutpuIndex
+}
+
+// MediaName returns the name of the stream.
+func (s *SourceOutput) MediaName() string {
+	return s.info.MediaName
+}
+
+// ClientName returns the name of the application that owns the stream, or an empty string if it is unknown.
+func (s *SourceOutput) ClientName() string {
+	return propString(s.info.Properties, "application.name")
+}
+
+// SourceIndex returns the index of the source the stream records from.
+func (s *SourceOutput) SourceIndex() uint32 {
+	return s.info.SourceIndex
+}
+
+// SetVolume sets the volume of all channels of the stream.
+// See (*Sink).SetVolume for the volume scale.
+func (s *SourceOutput) SetVolume(v float64) error {
+	return s.c.c().Request(&proto.SetSourceOutputVolume{
+		SourceOutputIndex: s.info.SourceOutpuIndex,
+		ChannelVolumes:    channelVolumes(len(s.info.ChannelMap), v),
+	}, nil)
+}
+
+// Volume returns the current volume of the stream. If the channels have different volumes, the highest one is returned.
+func (s *SourceOutput) Volume() (float64, error) {
+	if err := s.refresh(); err != nil {
+		return 0, err
+	}
+	return maxVolume(s.info.ChannelVolumes), nil
+}
+
+// SetMute mutes or unmutes the stream.
+func (s *SourceOutput) SetMute(mute bool) error {
+	return s.c.c().Request(&proto.SetSourceOutputMute{SourceOutputIndex: s.info.SourceOutpuIndex, Mute: mute}, nil)
+}
+
+// Muted returns whether the stream is currently muted.
+func (s *SourceOutput) Muted() (bool, error) {
+	if err := s.refresh(); err != nil {
+		return false, err
+	}
+	return s.info.Muted, nil
+}
+
+// MoveTo moves the stream to a different source.
+func (s *SourceOutput) MoveTo(source *Source) error {
+	err := s.c.c().Request(&proto.MoveSourceOutput{SourceOutputIndex: s.info.SourceOutpuIndex, DeviceIndex: source.info.SourceIndex}, nil)
+	if err != nil {
+		return err
+	}
+	s.info.SourceIndex = source.info.SourceIndex
+	return nil
+}
+
+// Kill forcibly closes the stream. The application that owns it is notified that the stream was killed.
+func (s *SourceOutput) Kill() error {
+	return s.c.c().Request(&proto.KillSourceOutput{SourceOutputIndex: s.info.SourceOutpuIndex}, nil)
+}
+
+// refresh requests the current state of the stream from the server.
+func (s *SourceOutput) refresh() error {
+	var info proto.GetSourceOutputInfoReply
+	err := s.c.c().Request(&proto.GetSourceOutputInfo{SourceOutpuIndex: s.info.SourceOutpuIndex}, &info)
+	if err != nil {
+		return err
+	}
+	s.info = info
+	return nil
+}
+
+// propString returns a string property, or an empty string if the property is missing or not a string.
+func propString(props proto.PropList, key string) string {
+	e := props[key]
+	if len(e) == 0 || e[len(e)-1] != 0 {
+		return ""
+	}
+	return string(e[:len(e)-1])
+}
@@ -4,37 +4,50 @@ import "github.com/jfreymuth/pulse/proto"
 
 // A Source is an input device.
 type Source struct {
+	c    *Client
 	info proto.GetSourceInfoReply
 }
 
 // ListSources returns a list of all available input devices.
 func (c *Client) ListSources() ([]*Source, error) {
 	var reply proto.GetSourceInfoListReply
-	err := c.c.Request(&proto.GetSourceInfoList{}, &reply)
+	err := c.c().Request(&proto.GetSourceInfoList{}, &reply)
 	if err != nil {
 		return nil, err
 	}
 	sinks := make([]*Source, len(reply))
 	for i := range sinks {
-		sinks[i] = &Source{info: *reply[i]}
+		sinks[i] = &Source{c: c, info: *reply[i]}
 	}
 	return sinks, nil
 }
 
 // DefaultSource returns the default input device.
 func (c *Client) DefaultSource() (*Source, error) {
-	var source Source
-	err := c.c.Request(&proto.GetSourceInfo{SourceIndex: proto.Undefined}, &source.info)
+	source := Source{c: c}
+	err := c.c().Request(&proto.GetSourceInfo{SourceIndex: proto.Undefined}, &source.info)
 	if err != nil {
 		return nil, err
 	}
 	return &source, nil
 }
 
+// SetDefaultSource makes the source the default source.
+// Depending on the server version and configuration, existing streams may be moved to the new default.
+func (c *Client) SetDefaultSource(source *Source) error {
+	return c.c().Request(&proto.SetDefaultSource{SourceName: source.info.SourceName}, nil)
+}
+
+// SourceByName looks up a source by its name, as returned by ID.
+// If there is no such source, the returned error is proto.ErrNoSuchEntity, which can be checked with errors.Is.
+func (c *Client) SourceByName(name string) (*Source, error) {
+	return c.SourceByID(name)
+}
+
 // SourceByID looks up a source id.
 func (c *Client) SourceByID(name string) (*Source, error) {
-	var source Source
-	err := c.c.Request(&proto.GetSourceInfo{SourceIndex: proto.Undefined, SourceName: name}, &source.info)
+	source := Source{c: c}
+	err := c.c().Request(&proto.GetSourceInfo{SourceIndex: proto.Undefined, SourceName: name}, &source.info)
 	if err != nil {
 		return nil, err
 	}
@@ -66,3 +79,79 @@ func (s *Source) SampleRate() int {
 func (s *Source) SourceIndex() uint32 {
 	return s.info.SourceIndex
 }
+
+// IsMonitor returns whether the source is the monitor of a sink, i.e. it records the audio played by that sink.
+func (s *Source) IsMonitor() bool {
+	return s.info.MonitorSourceIndex != proto.Undefined
+}
+
+// SampleSpec returns the source's sample format, rate and number of channels.
+func (s *Source) SampleSpec() proto.SampleSpec {
+	return s.info.SampleSpec
+}
+
+// Description returns the human-readable description of the source, e.g. "Built-in Audio Analog Stereo".
+// This is the same as Name.
+func (s *Source) Description() string {
+	return s.info.Device
+}
+
+// ChannelMap returns the source's channel map. This is the same as Channels.
+func (s *Source) ChannelMap() proto.ChannelMap {
+	return s.info.ChannelMap
+}
+
+// Formats returns the formats supported by the source.
+// For most sources this only contains PCM (proto.EncodingPCM), other encodings are used for passthrough of compressed audio.
+func (s *Source) Formats() []proto.FormatInfo {
+	return s.info.Formats
+}
+
+// SetVolume sets the volume of all channels of the source.
+// The volume is mapped the same way as for sinks, see (*Sink).SetVolume.
+func (s *Source) SetVolume(v float64) error {
+	return s.c.c().Request(&proto.SetSourceVolume{
+		SourceIndex:    s.info.SourceIndex,
+		ChannelVolumes: channelVolumes(len(s.info.ChannelMap), v),
+	}, nil)
+}
+
+// Volume returns the current volume of the source. If the channels have different volumes, the highest one is returned.
+// See SetVolume for the volume scale.
+func (s *Source) Volume() (float64, error) {
+	if err := s.refresh(); err != nil {
+		return 0, err
+	}
+	return maxVolume(s.info.ChannelVolumes), nil
+}
+
+// SetMute mutes or unmutes the source.
+// A muted source records silence for all clients, not only for this one.
+func (s *Source) SetMute(mute bool) error {
+	return s.c.c().Request(&proto.SetSourceMute{SourceIndex: s.info.SourceIndex, Mute: mute}, nil)
+}
+
+// Muted returns whether the source is currently muted.
+func (s *Source) Muted() (bool, error) {
+	if err := s.refresh(); err != nil {
+		return false, err
+	}
+	return s.info.Mute, nil
+}
+
+// SetSuspended suspends or resumes the source. A suspended source releases the audio device, e.g. to save power.
+// Streams connected to the source are paused while it is suspended.
+func (s *Source) SetSuspended(suspend bool) error {
+	return s.c.c().Request(&proto.SuspendSource{SourceIndex: s.info.SourceIndex, Suspend: suspend}, nil)
+}
+
+// refresh requests the current state of the source from the server.
+func (s *Source) refresh() error {
+	var info proto.GetSourceInfoReply
+	err := s.c.c().Request(&proto.GetSourceInfo{SourceIndex: s.info.SourceIndex}, &info)
+	if err != nil {
+		return err
+	}
+	s.info = info
+	return nil
+}
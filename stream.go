@@ -1,11 +1,18 @@
 package pulse
 
+// streamState is the state of a playback or record stream.
+//
+// A stream starts out idle. Start moves it to running, Pause and Resume switch between running and paused,
+// and Stop returns it to idle. A playback stream whose reader returned an error (including EndOfData) is ended,
+// it can be started again. Closed and serverLost are final, except that streams lost with the connection
+// may be restored by Reconnect.
 type streamState int
 
 const (
 	idle streamState = iota
 	running
 	paused
+	ended
 	closed
 	serverLost
 )
@@ -0,0 +1,41 @@
+package pulse
+
+import "github.com/jfreymuth/pulse/proto"
+
+// Volumes are mapped linearly onto the server's volume scale: 0 is muted, 1 is normal volume (100%).
+// Values above 1 amplify the audio, values below 0 are treated as 0.
+
+func toVolume(v float64) uint32 {
+	if v <= 0 {
+		return uint32(proto.VolumeMuted)
+	}
+	x := v * float64(proto.VolumeNorm)
+	if x >= float64(proto.VolumeMax) {
+		return uint32(proto.VolumeMax)
+	}
+	return uint32(x + .5)
+}
+
+func fromVolume(v uint32) float64 {
+	return float64(v) / float64(proto.VolumeNorm)
+}
+
+// channelVolumes returns volumes that set all channels to v.
+func channelVolumes(channels int, v float64) proto.ChannelVolumes {
+	cvol := make(proto.ChannelVolumes, channels)
+	for i := range cvol {
+		cvol[i] = toVolume(v)
+	}
+	return cvol
+}
+
+// maxVolume returns the volume of the loudest channel.
+func maxVolume(cvol proto.ChannelVolumes) float64 {
+	var max uint32
+	for _, v := range cvol {
+		if v > max {
+			max = v
+		}
+	}
+	return fromVolume(max)
+}
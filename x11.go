@@ -0,0 +1,208 @@
+//go:build x11
+// +build x11
+
+package pulse
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// x11Properties reads PULSE_SERVER and PULSE_COOKIE from the root window of the display named by $DISPLAY.
+// This implements just enough of the X11 protocol to do so, errors are ignored.
+func x11Properties() (server string, cookie []byte) {
+	host, display, ok := parseDisplay(os.Getenv("DISPLAY"))
+	if !ok {
+		return "", nil
+	}
+	var conn net.Conn
+	var err error
+	if host == "" || host == "unix" {
+		conn, err = net.DialTimeout("unix", "/tmp/.X11-unix/X"+display, time.Second)
+	} else {
+		n, _ := strconv.Atoi(display)
+		conn, err = net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(6000+n)), time.Second)
+	}
+	if err != nil {
+		return "", nil
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	x := &x11Conn{rw: conn}
+	root, err := x.setup(xauthCookie(display))
+	if err != nil {
+		return "", nil
+	}
+	if s, err := x.property(root, "PULSE_SERVER"); err == nil {
+		server = string(s)
+	}
+	if s, err := x.property(root, "PULSE_COOKIE"); err == nil {
+		cookie, _ = hex.DecodeString(strings.TrimSpace(string(s)))
+	}
+	return server, cookie
+}
+
+// parseDisplay splits a display name of the form [host]:display[.screen].
+func parseDisplay(d string) (host, display string, ok bool) {
+	i := strings.LastIndexByte(d, ':')
+	if i < 0 {
+		return "", "", false
+	}
+	host, display = d[:i], d[i+1:]
+	if j := strings.IndexByte(display, '.'); j >= 0 {
+		display = display[:j]
+	}
+	if _, err := strconv.Atoi(display); err != nil {
+		return "", "", false
+	}
+	return host, display, true
+}
+
+// xauthCookie returns the MIT-MAGIC-COOKIE-1 for the given display number, or nil if there is none.
+func xauthCookie(display string) []byte {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		path = os.Getenv("HOME") + "/.Xauthority"
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	field := func() []byte {
+		if len(data) < 2 {
+			return nil
+		}
+		n := 2 + int(binary.BigEndian.Uint16(data))
+		if len(data) < n {
+			return nil
+		}
+		b := data[2:n]
+		data = data[n:]
+		return b
+	}
+	// Each entry consists of a 16 bit family followed by address, display number, auth name and auth data.
+	for len(data) >= 2 {
+		data = data[2:]
+		field() // address
+		number := field()
+		name := field()
+		value := field()
+		if string(number) == display && string(name) == "MIT-MAGIC-COOKIE-1" {
+			return value
+		}
+	}
+	return nil
+}
+
+type x11Conn struct {
+	rw io.ReadWriter
+}
+
+var errX11 = errors.New("pulse: x11 request failed")
+
+func pad4(n int) int { return (n + 3) &^ 3 }
+
+// setup performs the connection setup and returns the root window of the first screen.
+func (x *x11Conn) setup(cookie []byte) (uint32, error) {
+	var name []byte
+	if cookie != nil {
+		name = []byte("MIT-MAGIC-COOKIE-1")
+	}
+	req := make([]byte, 12+pad4(len(name))+pad4(len(cookie)))
+	req[0] = 'l'
+	binary.LittleEndian.PutUint16(req[2:], 11)
+	binary.LittleEndian.PutUint16(req[6:], uint16(len(name)))
+	binary.LittleEndian.PutUint16(req[8:], uint16(len(cookie)))
+	copy(req[12:], name)
+	copy(req[12+pad4(len(name)):], cookie)
+	if _, err := x.rw.Write(req); err != nil {
+		return 0, err
+	}
+
+	var head [8]byte
+	if _, err := io.ReadFull(x.rw, head[:]); err != nil {
+		return 0, err
+	}
+	data := make([]byte, 4*int(binary.LittleEndian.Uint16(head[6:])))
+	if _, err := io.ReadFull(x.rw, data); err != nil {
+		return 0, err
+	}
+	if head[0] != 1 || len(data) < 32 {
+		return 0, errX11
+	}
+	vendor := int(binary.LittleEndian.Uint16(data[16:]))
+	formats := int(data[21])
+	offset := 32 + pad4(vendor) + 8*formats
+	if data[20] == 0 || len(data) < offset+4 {
+		return 0, errX11
+	}
+	return binary.LittleEndian.Uint32(data[offset:]), nil
+}
+
+// reply reads the reply to the last request.
+func (x *x11Conn) reply() ([]byte, error) {
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(x.rw, buf); err != nil {
+		return nil, err
+	}
+	if buf[0] != 1 {
+		return nil, errX11
+	}
+	extra := make([]byte, 4*int(binary.LittleEndian.Uint32(buf[4:])))
+	if _, err := io.ReadFull(x.rw, extra); err != nil {
+		return nil, err
+	}
+	return append(buf, extra...), nil
+}
+
+// property returns the value of a string property of the given window.
+func (x *x11Conn) property(window uint32, name string) ([]byte, error) {
+	req := make([]byte, 8+pad4(len(name)))
+	req[0] = 16 // InternAtom
+	req[1] = 1  // only if exists
+	binary.LittleEndian.PutUint16(req[2:], uint16(len(req)/4))
+	binary.LittleEndian.PutUint16(req[4:], uint16(len(name)))
+	copy(req[8:], name)
+	if _, err := x.rw.Write(req); err != nil {
+		return nil, err
+	}
+	rpl, err := x.reply()
+	if err != nil {
+		return nil, err
+	}
+	atom := binary.LittleEndian.Uint32(rpl[8:])
+	if atom == 0 {
+		return nil, errX11
+	}
+
+	req = make([]byte, 24)
+	req[0] = 20 // GetProperty
+	binary.LittleEndian.PutUint16(req[2:], 6)
+	binary.LittleEndian.PutUint32(req[4:], window)
+	binary.LittleEndian.PutUint32(req[8:], atom)
+	binary.LittleEndian.PutUint32(req[20:], 1<<16)
+	if _, err := x.rw.Write(req); err != nil {
+		return nil, err
+	}
+	rpl, err = x.reply()
+	if err != nil {
+		return nil, err
+	}
+	if rpl[1] != 8 {
+		return nil, errX11
+	}
+	n := int(binary.LittleEndian.Uint32(rpl[16:]))
+	if 32+n > len(rpl) {
+		return nil, errX11
+	}
+	return rpl[32 : 32+n], nil
+}
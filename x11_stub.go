@@ -0,0 +1,6 @@
+//go:build !x11
+// +build !x11
+
+package pulse
+
+func x11Properties() (server string, cookie []byte) { return "", nil }